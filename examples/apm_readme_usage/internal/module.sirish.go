@@ -1,5 +1,8 @@
-// Code generated by sirish. DO NOT EDIT.
-// Version 0.1.1
+// Code generated by github.com/pm1381/sirish. DO NOT EDIT.
+// sirish-hash: 42534756bcd734d9058bf52f0f83c913b4fff1ecd546e2afe54a5f838617ed39
+// sirish-source: module.go
+// sirish-directive: d47ca8888cca
+// Version
 
 package internal
 
@@ -9,6 +12,9 @@ import (
 	apm "go.elastic.co/apm/v2"
 )
 
+// a drifted wrapper fails at build time instead of where it is used
+var _ TestModule = (*TestModuleSirishWrapperImpl)(nil)
+
 type TestModuleSirishWrapperImpl struct {
 	// TODO: Visitor supports generics. add generics to template
 	name          string
@@ -22,6 +28,10 @@ func NewTestModuleSirishWrapperImpl(
 	wrapped TestModule,
 	tagType string,
 ) *TestModuleSirishWrapperImpl {
+	// wrapping twice would trace every call twice, keep the existing wrapper
+	if existing, ok := wrapped.(*TestModuleSirishWrapperImpl); ok {
+		return existing
+	}
 
 	return &TestModuleSirishWrapperImpl{
 		name:          name,
@@ -31,34 +41,39 @@ func NewTestModuleSirishWrapperImpl(
 	}
 }
 
+// Unwrap returns the TestModule implementation traced by this wrapper
+func (w *TestModuleSirishWrapperImpl) Unwrap() TestModule {
+	return w.wrapped
+}
+
 func (w *TestModuleSirishWrapperImpl) DoTest1(ctx_0_0 context.Context, req DoTest1Request, span int) (string, error) {
-	var DoTest1SpnrWpR_2_0 *apm.Span
-	DoTest1SpnrWpR_2_0, ctx_0_0 = apm.StartSpan(ctx_0_0, "TestModule.DoTest1", w.tagType)
-	DoTest1SpnrWpR_2_0.Context.SetLabel("label", w.name)
-	defer DoTest1SpnrWpR_2_0.End()
-	DoTest1ResUnkYfz_0_0, DoTest1ResUnULaT_1_0 := w.wrapped.DoTest1(ctx_0_0, req, span)
-	if DoTest1ResUnULaT_1_0 != nil {
-		apm.CaptureError(ctx_0_0, DoTest1ResUnULaT_1_0).SetSpan(DoTest1SpnrWpR_2_0)
-		DoTest1SpnrWpR_2_0.Outcome = "failure"
+	var DoTest1SpnnsAr_2_0 *apm.Span
+	DoTest1SpnnsAr_2_0, ctx_0_0 = apm.StartSpan(ctx_0_0, "TestModule.DoTest1", w.tagType)
+	DoTest1SpnnsAr_2_0.Context.SetLabel("label", w.name)
+	defer DoTest1SpnnsAr_2_0.End()
+	DoTest1ResUnXAXy_0_0, DoTest1ResUncphQ_1_0 := w.wrapped.DoTest1(ctx_0_0, req, span)
+	if DoTest1ResUncphQ_1_0 != nil {
+		apm.CaptureError(ctx_0_0, DoTest1ResUncphQ_1_0).SetSpan(DoTest1SpnnsAr_2_0)
+		DoTest1SpnnsAr_2_0.Outcome = "failure"
 	} else {
-		DoTest1SpnrWpR_2_0.Outcome = "success"
+		DoTest1SpnnsAr_2_0.Outcome = "success"
 	}
-	return DoTest1ResUnkYfz_0_0, DoTest1ResUnULaT_1_0
+	return DoTest1ResUnXAXy_0_0, DoTest1ResUncphQ_1_0
 }
 
 func (w *TestModuleSirishWrapperImpl) DoTest2(ctx_0_0 context.Context, req *DoTest2Request) (*DoTest2Response, error) {
-	var span *apm.Span
-	span, ctx_0_0 = apm.StartSpan(ctx_0_0, "TestModule.DoTest2", w.tagType)
-	span.Context.SetLabel("label", w.name)
-	defer span.End()
-	DoTest2ResUnMIZr_0_0, DoTest2ResUnMBhh_1_0 := w.wrapped.DoTest2(ctx_0_0, req)
-	if DoTest2ResUnMBhh_1_0 != nil {
-		apm.CaptureError(ctx_0_0, DoTest2ResUnMBhh_1_0).SetSpan(span)
-		span.Outcome = "failure"
+	var span1 *apm.Span
+	span1, ctx_0_0 = apm.StartSpan(ctx_0_0, "TestModule.DoTest2", w.tagType)
+	span1.Context.SetLabel("label", w.name)
+	defer span1.End()
+	DoTest2ResUnSwkH_0_0, DoTest2ResUntNhb_1_0 := w.wrapped.DoTest2(ctx_0_0, req)
+	if DoTest2ResUntNhb_1_0 != nil {
+		apm.CaptureError(ctx_0_0, DoTest2ResUntNhb_1_0).SetSpan(span1)
+		span1.Outcome = "failure"
 	} else {
-		span.Outcome = "success"
+		span1.Outcome = "success"
 	}
-	return DoTest2ResUnMIZr_0_0, DoTest2ResUnMBhh_1_0
+	return DoTest2ResUnSwkH_0_0, DoTest2ResUntNhb_1_0
 }
 
 func (w *TestModuleSirishWrapperImpl) DoNext() (ctx context.Context, res interface{}, err error) {
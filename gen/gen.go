@@ -0,0 +1,468 @@
+// Package gen is the generator behind the sirish command, for build tools which call it in process.
+// Load parses a file into a Model, Generate renders the wrappers of a model in memory and Run does both.
+// Nothing is written to disk, the returned files are written by the caller, e.g. with Write and a Sink.
+//
+// The types of the package are its own, the model the templates are executed with is mapped onto Interface
+// and Method, so fields the templates need are not part of it and their changes do not break its callers.
+// Fields are only added to them, their json is the one sirish inspect -json prints.
+package gen
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/templates"
+	"github.com/pm1381/sirish/internal/visitors"
+	"github.com/pm1381/sirish/internal/wrapper"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// File is a rendered wrapper
+type File struct {
+	Path      string // where the wrapper is written
+	Content   []byte
+	Interface string // name of the wrapped interface
+}
+
+// Sink is where Write puts generated files. Close is called once every file is written
+type Sink interface {
+	Write(file File) error
+	Close() error
+}
+
+// Status tells what writing a file to disk would do
+type Status string
+
+const (
+	StatusCreate    Status = "create"
+	StatusModify    Status = "modify"
+	StatusUnchanged Status = "unchanged"
+)
+
+// WrapperOptions shape the wrappers, like the flags of the sirish command with the same names
+type WrapperOptions struct {
+	Version   string            // printed in the header of the wrappers
+	Imports   bool              // format the imports of the wrappers
+	CreateTx  bool              // start a transaction when the context of a call has none
+	Streams   bool              // keep spans of streaming results open until the stream is consumed
+	Closers   bool              // keep spans of closable results open until they are closed
+	Callbacks bool              // trace function params receiving a context as child spans
+	SpanType  string            // span type of wrappers constructed with an empty one
+	Labels    map[string]string // labels set on every span next to the wrapper name
+}
+
+// Options are the inputs of Load and Generate
+type Options struct {
+	File       string                    // go file to parse, relative paths are relative to the working directory
+	Types      []string                  // targets besides the ones marked with //sirish:, import/path.Name for other packages
+	OutDir     string                    // directory of the wrappers, relative to File. empty keeps them next to it
+	OutPkg     string                    // package of the wrappers written to OutDir
+	Suffix     string                    // suffix of the generated files and types, sirish when empty
+	Backend    string                    // apm when empty, otherwise the name of a sirish-gen-<name> plugin on PATH
+	Template   string                    // file or directory of templates replacing the embedded one, apm only
+	Wrapper    WrapperOptions            // options of every wrapper
+	Interfaces map[string]WrapperOptions // options replacing Wrapper for the interfaces named by the keys
+	Force      bool                      // render every wrapper, even the ones whose header holds their current source hash
+	Jobs       int                       // workers loading, rendering and formatting, GOMAXPROCS when not positive
+}
+
+// DefaultOptions are the options the sirish command uses for file without flags or config
+func DefaultOptions(file string) Options {
+	return Options{
+		File:    file,
+		Suffix:  "sirish",
+		Backend: "apm",
+		Wrapper: WrapperOptions{
+			Imports:   true,
+			CreateTx:  true,
+			Callbacks: true,
+		},
+	}
+}
+
+// Model is what Load parses from a file. Generate, Check and Orphans render the targets Load parsed which
+// Interfaces still lists, so callers may leave some out, but the fields of Interfaces are not read back
+type Model struct {
+	File       string      // absolute path of the parsed file
+	Imports    Imports     // every import of the file
+	Interfaces []Interface // the targets, in the order of the file followed by the ones of other packages
+
+	infos []dto.InterfaceInfo // the targets as the templates see them
+}
+
+// targets are the parsed targets model.Interfaces lists, in its order
+func (model *Model) targets() []dto.InterfaceInfo {
+	type key struct{ file, name string }
+	infos := make(map[key]dto.InterfaceInfo, len(model.infos))
+	for _, info := range model.infos {
+		infos[key{info.FilePath, info.Name}] = info
+	}
+	var targets []dto.InterfaceInfo
+	for _, target := range model.Interfaces {
+		if info, ok := infos[key{target.FilePath, target.Name}]; ok {
+			targets = append(targets, info)
+		}
+	}
+	return targets
+}
+
+// Load parses the targets of opts.File: opts.Types, the ones marked by its comments and their structs.
+// the ones written as import/path.Name are loaded from their package through the module of the file
+func Load(opts Options) (*Model, error) {
+	return load(opts, visitors.NewLoader())
+}
+
+// load parses opts.File once for both visitors, the other files of its package come from the cache of loader
+func load(opts Options, loader *visitors.Loader) (*Model, error) {
+	if opts.File == "" {
+		return nil, errors.New("no file to load")
+	}
+	file, err := filepath.Abs(opts.File)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := loader.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	// parse comments for //sirish:InterfaceName, before the type visitor changes the AST
+	commentVisitor := visitors.NewCommentVisitor(file)
+	commentVisitor.Walk(parsed)
+	local, external := visitors.SplitTargets(internal.GenerateUniqueValues(opts.Types, commentVisitor.GetTargets()))
+	typeVisitor := visitors.NewTypeVisitor(file, local)
+	typeVisitor.SetLoader(loader)
+	typeVisitor.SetStructs(commentVisitor.GetStructs())
+	typeVisitor.SetOutput(opts.OutDir, opts.OutPkg)
+	if err = typeVisitor.TraverseFile(parsed); err != nil {
+		return nil, err
+	}
+	externalInterfaces, err := visitors.TraverseExternal(loader, filepath.Dir(file), external, opts.OutDir, opts.OutPkg, opts.Jobs)
+	if err != nil {
+		return nil, err
+	}
+	model := &Model{
+		File:    file,
+		Imports: newImports(typeVisitor.GetImports()),
+		infos:   append(typeVisitor.GetWrappedInterfaces(), externalInterfaces...),
+	}
+	for _, info := range model.infos {
+		model.Interfaces = append(model.Interfaces, newInterface(info))
+	}
+	return model, nil
+}
+
+// LoadAll loads every file of files with opts on opts.Jobs workers. the files of a package are parsed once
+// for all of them. the models keep the order of files, failing files have a nil model and their errors are
+// returned together
+func LoadAll(opts Options, files []string) ([]*Model, error) {
+	loader := visitors.NewLoader()
+	return internal.ParallelMap(opts.Jobs, files, func(file string) (*Model, error) {
+		fileOpts := opts
+		fileOpts.File = file
+		model, err := load(fileOpts, loader)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		return model, nil
+	})
+}
+
+// Generate renders the wrappers of model with the backend of opts, in the order of model.Interfaces
+func Generate(model *Model, opts Options) ([]File, error) {
+	generator, err := newGenerator(model, opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := generator.Render(wrapperOptions(model, opts))
+	if err != nil {
+		return nil, err
+	}
+	return newFiles(files), nil
+}
+
+// FileStatus is what generate would do to a wrapper
+type FileStatus struct {
+	Path      string
+	Interface string
+	Status    Status
+}
+
+// Check tells whether generate would create, modify or leave each wrapper of model unchanged. apm wrappers
+// are compared by the source hash in their header without rendering them. the ones without a hash, like
+// the wrappers of plugins and of templates without a Code generated line, are rendered and compared whole
+func Check(model *Model, opts Options) ([]FileStatus, error) {
+	generator, err := newGenerator(model, opts)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []FileStatus
+	rendered := false // whether some statuses are only known once the files are rendered
+	if hashing, ok := generator.(wrapper.HashingWrapper); ok {
+		hashes, err := hashing.Hashes(wrapperOptions(model, opts))
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range hashes {
+			status, err := compareHash(hash)
+			if err != nil {
+				return nil, err
+			}
+			rendered = rendered || status == ""
+			statuses = append(statuses, FileStatus{Path: hash.Path, Interface: hash.Interface, Status: status})
+		}
+	} else {
+		rendered = true
+	}
+	if !rendered {
+		return statuses, nil
+	}
+
+	files, err := generator.Render(wrapperOptions(model, opts))
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]Status, len(statuses))
+	for _, status := range statuses {
+		known[status.Path] = status.Status
+	}
+	statuses = statuses[:0]
+	for _, file := range files {
+		status := known[file.Path]
+		if status == "" {
+			if status, err = Compare(File(file)); err != nil {
+				return nil, err
+			}
+		}
+		statuses = append(statuses, FileStatus{Path: file.Path, Interface: file.Interface, Status: status})
+	}
+	return statuses, nil
+}
+
+// compareHash tells what generate would do to the file of hash, empty when the file has no hash to compare
+func compareHash(hash wrapper.FileHash) (Status, error) {
+	existing, err := wrapper.ReadHash(hash.Path)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case existing == hash.Hash:
+		return StatusUnchanged, nil
+	case existing != "":
+		return StatusModify, nil
+	}
+	if _, err = os.Stat(hash.Path); errors.Is(err, os.ErrNotExist) {
+		return StatusCreate, nil
+	}
+	return "", err
+}
+
+func wrapperOptions(model *Model, opts Options) wrapper.APMTypeWrapperOptions {
+	interfaces := make(map[string]wrapper.GeneralOptions, len(opts.Interfaces))
+	for name, options := range opts.Interfaces {
+		interfaces[name] = wrapper.GeneralOptions(options)
+	}
+	return wrapper.APMTypeWrapperOptions{
+		GeneralOptions: wrapper.GeneralOptions(opts.Wrapper),
+		Interfaces:     interfaces,
+		Incremental:    !opts.Force,
+		Jobs:           opts.Jobs,
+		Source:         model.File,
+		Directive:      directive(opts),
+	}
+}
+
+// directive identifies the go:generate directive of a file opts come from by where and how it writes its
+// wrappers: the output directory relative to the file, the suffix and the backend. the targets are left out,
+// a directive whose -t target is renamed still owns the wrapper of the old name
+func directive(opts Options) string {
+	srcDir := filepath.Dir(opts.File)
+	outDir := visitors.OutputDirectory(opts.File, opts.OutDir, opts.OutPkg)
+	if rel, err := filepath.Rel(srcDir, outDir); err == nil {
+		outDir = rel // moving the checkout does not change the id
+	}
+	suffix, backend := opts.Suffix, opts.Backend
+	if suffix == "" {
+		suffix = "sirish"
+	}
+	if backend == "" {
+		backend = "apm"
+	}
+	content, _ := json.Marshal([]string{filepath.ToSlash(outDir), suffix, backend})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:6])
+}
+
+// Orphans lists the wrappers generated from model.File by the directive of opts which keep, the paths of its
+// current wrappers, does not hold anymore, like the ones of a deleted or renamed target. the directory of
+// model.File, the output directory of opts and the directories of keep are searched. only files whose header
+// names both model.File and the directive are listed, the wrappers of the other directives of the file, and
+// the ones written before sirish stamped them, are left alone
+func Orphans(model *Model, opts Options, keep []string) ([]string, error) {
+	id := directive(opts)
+	dirs := []string{filepath.Dir(model.File), visitors.OutputDirectory(model.File, opts.OutDir, opts.OutPkg)}
+	kept := make(map[string]bool, len(keep))
+	for _, p := range keep {
+		kept[filepath.Clean(p)] = true
+		dirs = append(dirs, filepath.Dir(filepath.Clean(p)))
+	}
+
+	var orphans []string
+	for _, dir := range internal.GenerateUniqueValues(nil, dirs) {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			p := filepath.Join(dir, name)
+			if entry.IsDir() || filepath.Ext(name) != ".go" || kept[p] || p == model.File {
+				continue
+			}
+			header, err := wrapper.ReadHeader(p)
+			if err != nil {
+				return nil, err
+			}
+			if header.Generated && header.Source == model.File && header.Directive == id {
+				orphans = append(orphans, p)
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// Run loads opts.File and generates its wrappers
+func Run(opts Options) ([]File, error) {
+	model, err := Load(opts)
+	if err != nil {
+		return nil, err
+	}
+	return Generate(model, opts)
+}
+
+// OutputPath is the file the apm backend writes the wrapper of info to
+func OutputPath(info Interface, suffix string) string {
+	return wrapper.OutputPath(dto.InterfaceInfo{FileName: info.FileName, OutDirectory: info.OutDirectory}, suffix)
+}
+
+func newGenerator(model *Model, opts Options) (wrapper.WrapperInterface, error) {
+	if opts.Backend != "" && opts.Backend != "apm" {
+		if opts.Template != "" {
+			return nil, errors.New("templates only apply to the apm backend, not to " + opts.Backend)
+		}
+		return wrapper.NewPluginWrapper(opts.Backend, filepath.Dir(model.File), opts.Suffix, model.targets()), nil
+	}
+	if opts.Template == "" {
+		return wrapper.NewApmWrapper(opts.Suffix, wrapper.EntryTemplate, templates.FS, model.targets()), nil
+	}
+	tmpl, err := wrapper.LoadTemplate(opts.Template)
+	if err != nil {
+		return nil, err
+	}
+	return wrapper.NewApmWrapperWithTemplate(opts.Suffix, tmpl, model.targets()), nil
+}
+
+// Write writes files to sink and closes it
+func Write(sink Sink, files []File) error {
+	internalFiles := make([]wrapper.File, 0, len(files))
+	for _, file := range files {
+		internalFiles = append(internalFiles, wrapper.File(file))
+	}
+	return wrapper.WriteFiles(wrapperSink{sink: sink}, internalFiles)
+}
+
+// Compare tells whether writing file to disk would create, modify or leave it unchanged
+func Compare(file File) (Status, error) {
+	status, err := wrapper.Compare(wrapper.File(file))
+	return Status(status), err
+}
+
+// NewDiskSink writes every file to its path
+func NewDiskSink() Sink {
+	return sink{sink: wrapper.NewDiskSink()}
+}
+
+// MemorySink keeps written files in a map by path
+type MemorySink struct {
+	sink *wrapper.MemorySink
+}
+
+// NewMemorySink keeps the files in memory, Files returns them
+func NewMemorySink() *MemorySink {
+	return &MemorySink{sink: wrapper.NewMemorySink()}
+}
+
+func (ms *MemorySink) Write(file File) error {
+	return ms.sink.Write(wrapper.File(file))
+}
+
+func (ms *MemorySink) Close() error {
+	return ms.sink.Close()
+}
+
+// Files returns the content of the written files by path
+func (ms *MemorySink) Files() map[string][]byte {
+	return ms.sink.Files()
+}
+
+// NewWriterSink prints every file to w after a comment naming its path
+func NewWriterSink(w io.Writer) Sink {
+	return sink{sink: wrapper.NewWriterSink(w)}
+}
+
+// NewTarSink writes a tar archive of the files to w, named relative to root
+func NewTarSink(w io.Writer, root string) Sink {
+	return sink{sink: wrapper.NewTarSink(w, root)}
+}
+
+// NewZipSink writes a zip archive of the files to w, named relative to root
+func NewZipSink(w io.Writer, root string) Sink {
+	return sink{sink: wrapper.NewZipSink(w, root)}
+}
+
+// NewDryRunSink writes nothing, it prints whether every file would be created, modified or left unchanged
+func NewDryRunSink(w io.Writer, root string) Sink {
+	return sink{sink: wrapper.NewDryRunSink(w, root)}
+}
+
+// sink is a Sink of the renderer
+type sink struct {
+	sink wrapper.Sink
+}
+
+func (s sink) Write(file File) error {
+	return s.sink.Write(wrapper.File(file))
+}
+
+func (s sink) Close() error {
+	return s.sink.Close()
+}
+
+// wrapperSink hands the files of the renderer to a Sink
+type wrapperSink struct {
+	sink Sink
+}
+
+func (ws wrapperSink) Write(file wrapper.File) error {
+	return ws.sink.Write(File(file))
+}
+
+func (ws wrapperSink) Close() error {
+	return ws.sink.Close()
+}
+
+func newFiles(files []wrapper.File) []File {
+	res := make([]File, 0, len(files))
+	for _, file := range files {
+		res = append(res, File(file))
+	}
+	return res
+}
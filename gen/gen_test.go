@@ -0,0 +1,252 @@
+package gen
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	model, err := Load(DefaultOptions(filepath.Join("test_samples", "store.go")))
+	require.NoError(t, err)
+
+	abs, err := filepath.Abs(filepath.Join("test_samples", "store.go"))
+	require.NoError(t, err)
+	assert.Equal(t, abs, model.File)
+	assert.Equal(t, Imports{"context": "context"}, model.Imports)
+	require.Len(t, model.Interfaces, 1)
+	profileStore := model.Interfaces[0]
+	assert.Equal(t, "ProfileStore", profileStore.Name)
+	assert.Equal(t, Position{File: abs, Line: 11, Column: 6}, profileStore.Position)
+	require.Len(t, profileStore.Methods, 2)
+	assert.Equal(t, "Get", profileStore.Methods[0].Name)
+}
+
+func TestLoad_Model(t *testing.T) {
+	model, err := Load(DefaultOptions(filepath.Join("test_samples", "store.go")))
+	require.NoError(t, err)
+
+	get := model.Interfaces[0].Methods[0]
+	assert.Equal(t, "ProfileStore.Get", get.SpecialName)
+	assert.Equal(t, []Param{{Name: "ctx_0_0", Type: "context.Context"}, {Name: "id", Type: "string"}}, get.Params)
+	assert.Equal(t, "Profile", get.Results[0].Type)
+	assert.True(t, get.HasCtx)
+	assert.True(t, get.HasError)
+	assert.Equal(t, "ctx_0_0", get.CtxName)
+
+	// the json of the model is the one of the interfaces the templates see, without their template fields
+	internal, err := json.Marshal(model.infos)
+	require.NoError(t, err)
+	public, err := json.Marshal(model.Interfaces)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(internal), string(public))
+}
+
+func TestLoad_Errors(t *testing.T) {
+	_, err := Load(Options{})
+	assert.EqualError(t, err, "no file to load")
+
+	_, err = Load(DefaultOptions(filepath.Join("test_samples", "missing.go")))
+	assert.Error(t, err)
+
+	// a target which cannot be resolved is an error of Load, not an exit of the process
+	file := filepath.Join(t.TempDir(), "store.go")
+	require.NoError(t, os.WriteFile(file, []byte("package store\n\n// sirish:Store\ntype Store interface {\n\tGet(id missing.ID) error\n}\n"), 0o644))
+	_, err = Load(DefaultOptions(file))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot find the import of package missing")
+	models, err := LoadAll(DefaultOptions(""), []string{file})
+	require.Error(t, err)
+	assert.Equal(t, []*Model{nil}, models)
+}
+
+func TestRun_InMemory(t *testing.T) {
+	opts := DefaultOptions(filepath.Join("test_samples", "store.go"))
+	opts.Suffix = "traced"
+	opts.Wrapper.Labels = map[string]string{"team": "payments"}
+	files, err := Run(opts)
+	require.NoError(t, err)
+
+	require.Len(t, files, 1)
+	abs, err := filepath.Abs(filepath.Join("test_samples", "store.traced.go"))
+	require.NoError(t, err)
+	assert.Equal(t, abs, files[0].Path)
+	assert.Equal(t, "ProfileStore", files[0].Interface)
+	assert.Contains(t, string(files[0].Content), "type ProfileStoreTracedWrapperImpl struct")
+	assert.Contains(t, string(files[0].Content), `SetLabel("team", "payments")`)
+	assert.NoFileExists(t, abs) // the caller decides where files go
+}
+
+func TestGenerate_PerInterfaceOptions(t *testing.T) {
+	opts := DefaultOptions(filepath.Join("test_samples", "store.go"))
+	model, err := Load(opts)
+	require.NoError(t, err)
+
+	override := opts.Wrapper
+	override.SpanType = "db"
+	opts.Interfaces = map[string]WrapperOptions{"ProfileStore": override}
+	files, err := Generate(model, opts)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Contains(t, string(files[0].Content), `tagType = "db"`)
+}
+
+func TestGenerate_LeftOutInterfaces(t *testing.T) {
+	opts := DefaultOptions(filepath.Join("test_samples", "store.go"))
+	model, err := Load(opts)
+	require.NoError(t, err)
+
+	model.Interfaces = model.Interfaces[:0]
+	files, err := Generate(model, opts)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestGenerate_TemplateOnlyForApm(t *testing.T) {
+	opts := DefaultOptions(filepath.Join("test_samples", "store.go"))
+	opts.Backend = "otel"
+	opts.Template = filepath.Join(os.TempDir(), "wrapper.gotmpl")
+	_, err := Run(opts)
+	assert.EqualError(t, err, "templates only apply to the apm backend, not to otel")
+}
+
+func TestWrite_MemorySink(t *testing.T) {
+	files, err := Run(DefaultOptions(filepath.Join("test_samples", "store.go")))
+	require.NoError(t, err)
+
+	sink := NewMemorySink()
+	require.NoError(t, Write(sink, files))
+	assert.Equal(t, map[string][]byte{files[0].Path: files[0].Content}, sink.Files())
+
+	status, err := Compare(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, StatusCreate, status)
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	opts := DefaultOptions(filepath.Join("test_samples", "store.go"))
+	opts.OutDir, opts.OutPkg = dir, "traced"
+	model, err := Load(opts)
+	require.NoError(t, err)
+
+	statuses, err := Check(model, opts)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, StatusCreate, statuses[0].Status)
+	assert.Equal(t, "ProfileStore", statuses[0].Interface)
+
+	files, err := Generate(model, opts)
+	require.NoError(t, err)
+	require.NoError(t, Write(NewDiskSink(), files))
+	statuses, err = Check(model, opts)
+	require.NoError(t, err)
+	assert.Equal(t, StatusUnchanged, statuses[0].Status)
+
+	opts.Wrapper.SpanType = "db"
+	statuses, err = Check(model, opts)
+	require.NoError(t, err)
+	assert.Equal(t, StatusModify, statuses[0].Status)
+}
+
+func TestLoadAll(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0o644))
+	var files []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		file := filepath.Join(dir, name+".go")
+		src := "package m\n\nimport \"context\"\n\n//sirish:Store" + name + "\ntype Store" + name +
+			" interface {\n\tGet(ctx context.Context, id string) error\n}\n"
+		require.NoError(t, os.WriteFile(file, []byte(src), 0o644))
+		files = append(files, file)
+	}
+	opts := DefaultOptions("")
+	opts.Jobs = 4
+	models, err := LoadAll(opts, files)
+	require.NoError(t, err)
+	require.Len(t, models, 4)
+	for i, model := range models {
+		assert.Equal(t, files[i], model.File)
+		require.Len(t, model.Interfaces, 1)
+		assert.Equal(t, "Store"+filepath.Base(files[i])[:1], model.Interfaces[0].Name)
+	}
+
+	_, err = LoadAll(opts, append(files, filepath.Join(dir, "missing.go")))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing.go")
+}
+
+func TestOrphans(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0o644))
+	src := "package m\n\nimport \"context\"\n\n//sirish:Store\ntype Store interface {\n\tGet(ctx context.Context, id string) error\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.go"), []byte(src), 0o644))
+	opts := DefaultOptions(filepath.Join(dir, "a.go"))
+	opts.OutDir = "traced"
+	opts.Wrapper.Imports = false // formatting imports would touch go.sum
+	model, err := Load(opts)
+	require.NoError(t, err)
+	files, err := Generate(model, opts)
+	require.NoError(t, err)
+	require.NoError(t, Write(NewDiskSink(), files))
+
+	const header = "// Code generated by github.com/pm1381/sirish. DO NOT EDIT.\n"
+	write := func(name string, content string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, []byte(content+"\npackage m\n"), 0o644))
+		return p
+	}
+	other := opts
+	other.OutDir = ""
+	stamp := func(source string, opts Options) string {
+		return header + "// sirish-source: " + source + "\n// sirish-directive: " + directive(opts) + "\n"
+	}
+	orphans := []string{
+		write(filepath.Join("traced", "Gone.a.sirish.go"), stamp("../a.go", opts)),
+		write("Moved.a.sirish.go", stamp("a.go", opts)),
+	}
+	write("a.sirish.go", stamp("a.go", other))                                              // another directive of a.go
+	write("Old.a.sirish.go", header)                                                        // older than the header lines
+	write(filepath.Join("traced", "Old.a.sirish.go"), header+"// sirish-source: ../a.go\n") // no directive line
+	write("hand.a.sirish.go", "// written by hand\n")                                       // no header
+	write(filepath.Join("traced", "b.sirish.go"), stamp("../b.go", opts))                   // another file
+
+	keep := []string{files[0].Path}
+	found, err := Orphans(model, opts, keep)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, orphans, found)
+
+	// the wrappers written now name a.go and the directive
+	found, err = Orphans(model, opts, nil)
+	require.NoError(t, err)
+	assert.Contains(t, found, files[0].Path)
+	found, err = Orphans(model, other, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.sirish.go")}, found)
+}
+
+func TestDirective(t *testing.T) {
+	opts := DefaultOptions(filepath.Join("/src", "a.go"))
+	opts.Types = []string{"Shadow"}
+	same := DefaultOptions(filepath.Join("/ci", "src", "a.go"))
+	same.Types = []string{"Shade", "Foo"}
+	same.Suffix, same.Backend = "", ""
+	same.Wrapper = WrapperOptions{}
+	assert.Equal(t, directive(opts), directive(same), "targets, defaults, wrapper options and the checkout do not matter")
+	same.OutPkg = "tracing"
+	opts.OutDir = "tracing"
+	assert.Equal(t, directive(opts), directive(same), "-out-pkg alone writes to the directory of its name")
+
+	for name, change := range map[string]func(*Options){
+		"out dir": func(o *Options) { o.OutDir = "traced" },
+		"suffix":  func(o *Options) { o.Suffix = "traced" },
+		"backend": func(o *Options) { o.Backend = "otel" },
+	} {
+		other := opts
+		change(&other)
+		assert.NotEqual(t, directive(opts), directive(other), name)
+	}
+}
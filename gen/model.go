@@ -0,0 +1,198 @@
+package gen
+
+import (
+	"github.com/pm1381/sirish/internal/dto"
+)
+
+// Interface is an interface, struct or function type to wrap, with its methods and where its wrapper goes.
+// its json is the one sirish inspect -json prints
+type Interface struct {
+	Name          string      `json:"name"`
+	TypeParams    []TypeParam `json:"type_params"`
+	Methods       []Method    `json:"methods"`
+	Closable      bool        `json:"closable"`  // has a Close() error method
+	Imports       Imports     `json:"imports"`   // packages the method signatures refer to, with the names they use
+	FileName      string      `json:"file_name"` // base name of the file declaring it
+	FilePath      string      `json:"file_path"`
+	Package       string      `json:"package"`
+	Directory     string      `json:"directory"`
+	QualifiedName string      `json:"qualified_name"` // Name as the output package refers to it
+	OutPackage    string      `json:"out_package"`    // package the wrapper is generated in
+	OutDirectory  string      `json:"out_directory"`  // directory the wrapper is generated in
+	Declaration   string      `json:"declaration"`    // interface type sirish generates for Struct, empty for declared interfaces
+	Struct        string      `json:"struct"`         // struct implementing the generated interface
+	Func          bool        `json:"func"`           // a function type with a single method named after it, wrapped by Trace<Name>
+	Position      Position    `json:"position"`       // where the interface, function type or struct is declared
+}
+
+// TypeParam is a type parameter of a generic Interface
+type TypeParam struct {
+	Name       string `json:"name"`       // for example T or K
+	Constraint string `json:"constraint"` // for example any, comparable or {interface | int64}
+}
+
+// Method is a method of an Interface
+type Method struct {
+	Name           string   `json:"name"`
+	SpecialName    string   `json:"special_name"` // name of its span, Interface.Method
+	Params         []Param  `json:"params"`
+	Results        []Result `json:"results"`
+	HasNamedResult bool     `json:"has_named_result"`
+	HasCtx         bool     `json:"has_ctx"`
+	HasError       bool     `json:"has_error"`
+	ErrorName      string   `json:"error_name"`
+	CtxName        string   `json:"ctx_name"`
+	Stream         *Stream  `json:"stream"`   // first streaming result, nil if the method returns none
+	Closer         *Closer  `json:"closer"`   // first closable result, nil if the method returns none
+	Position       Position `json:"position"` // where the method is declared
+}
+
+// Param is a param of a Method or a Callback
+type Param struct {
+	Name     string    `json:"name"`     // can be empty
+	Type     string    `json:"type"`     // printable type, e.g. "context.Context"
+	Callback *Callback `json:"callback"` // set when the param is a function receiving a context first
+}
+
+// Result is a result of a Method or a Callback
+type Result struct {
+	Name string `json:"name"` // can be empty
+	Type string `json:"type"` // printable type, e.g. "context.Context"
+	Zero string `json:"zero"` // expression of its zero value, e.g. nil, 0, "", T{} or *new(T)
+}
+
+// Callback is a function param the wrapper traces as a child span of the method
+type Callback struct {
+	SpanName  string   `json:"span_name"` // e.g. "Store.WithTx.fn"
+	CtxName   string   `json:"ctx_name"`  // name of the context argument
+	Params    []Param  `json:"params"`
+	Results   []Result `json:"results"`
+	Variadic  bool     `json:"variadic"`
+	ErrorName string   `json:"error_name"` // last error result, empty if the callback returns none
+}
+
+// Stream is a result whose real work happens after the method returns
+type Stream struct {
+	Kind     string `json:"kind"`      // chan, seq, seq2 or cursor
+	Result   string `json:"result"`    // name of the result holding the stream
+	KeyType  string `json:"key_type"`  // K of iter.Seq2, empty otherwise
+	ElemType string `json:"elem_type"` // T of the stream, V of iter.Seq2
+}
+
+// Closer is a result whose lifetime ends when it is closed
+type Closer struct {
+	Kind   string `json:"kind"`   // Closer, ReadCloser, WriteCloser, ReadWriteCloser or Target
+	Result string `json:"result"` // name of the result holding the resource
+	Type   string `json:"type"`   // printable type, e.g. "io.ReadCloser" or the target interface name
+	Closes bool   `json:"closes"` // whether closing the resource ends the span, false for targets without Close() error
+}
+
+// Position is where a declaration starts in its source file, lines and columns start at 1
+type Position struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// Imports maps import paths to the names a file refers to them with
+type Imports map[string]string
+
+// newInterface maps the model the templates are executed with onto its public form
+func newInterface(info dto.InterfaceInfo) Interface {
+	res := Interface{
+		Name:          info.Name,
+		Closable:      info.Closable,
+		Imports:       newImports(info.Imports),
+		FileName:      info.FileName,
+		FilePath:      info.FilePath,
+		Package:       info.Package,
+		Directory:     info.Directory,
+		QualifiedName: info.QualifiedName,
+		OutPackage:    info.OutPackage,
+		OutDirectory:  info.OutDirectory,
+		Declaration:   info.Declaration,
+		Struct:        info.Struct,
+		Func:          info.Func,
+		Position:      Position(info.Position),
+	}
+	// nil lists stay nil and empty ones empty, so the json keeps its null and []
+	if info.TypeParams != nil {
+		res.TypeParams = make([]TypeParam, 0, len(info.TypeParams))
+	}
+	for _, param := range info.TypeParams {
+		res.TypeParams = append(res.TypeParams, TypeParam(param))
+	}
+	if info.Methods != nil {
+		res.Methods = make([]Method, 0, len(info.Methods))
+	}
+	for _, method := range info.Methods {
+		res.Methods = append(res.Methods, newMethod(method))
+	}
+	return res
+}
+
+func newMethod(method dto.Method) Method {
+	res := Method{
+		Name:           method.Name,
+		SpecialName:    method.SpecialName,
+		Params:         newParams(method.Params),
+		Results:        newResults(method.Results),
+		HasNamedResult: method.HasNamedResult,
+		HasCtx:         method.HasCtx,
+		HasError:       method.HasError,
+		ErrorName:      method.ErrorName,
+		CtxName:        method.CtxName,
+		Position:       Position(method.Position),
+	}
+	if stream := method.Stream; stream != nil {
+		res.Stream = &Stream{Kind: string(stream.Kind), Result: stream.Result, KeyType: stream.KeyType, ElemType: stream.ElemType}
+	}
+	if closer := method.Closer; closer != nil {
+		res.Closer = &Closer{Kind: string(closer.Kind), Result: closer.Result, Type: closer.Type, Closes: closer.Closes}
+	}
+	return res
+}
+
+func newParams(params []dto.ParamInfo) []Param {
+	if params == nil {
+		return nil
+	}
+	res := make([]Param, 0, len(params))
+	for _, param := range params {
+		p := Param{Name: param.Name, Type: param.Type}
+		if callback := param.Callback; callback != nil {
+			p.Callback = &Callback{
+				SpanName:  callback.SpanName,
+				CtxName:   callback.CtxName,
+				Params:    newParams(callback.Params),
+				Results:   newResults(callback.Results),
+				Variadic:  callback.Variadic,
+				ErrorName: callback.ErrorName,
+			}
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+func newResults(results []dto.ResultInfo) []Result {
+	if results == nil {
+		return nil
+	}
+	res := make([]Result, 0, len(results))
+	for _, result := range results {
+		res = append(res, Result(result))
+	}
+	return res
+}
+
+func newImports(imports dto.PkgImports) Imports {
+	if imports == nil {
+		return nil
+	}
+	res := make(Imports, len(imports))
+	for path, name := range imports {
+		res[path] = name
+	}
+	return res
+}
@@ -0,0 +1,14 @@
+package test_samples
+
+import "context"
+
+type Profile struct {
+	ID   string
+	Name string
+}
+
+// sirish:ProfileStore
+type ProfileStore interface {
+	Get(ctx context.Context, id string) (Profile, error)
+	Save(ctx context.Context, profile Profile) error
+}
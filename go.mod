@@ -7,7 +7,9 @@ require (
 	github.com/stretchr/testify v1.11.1
 	go.elastic.co/apm/module/apmechov4 v1.15.0
 	go.elastic.co/apm/v2 v2.7.2
+	golang.org/x/mod v0.30.0
 	golang.org/x/tools v0.39.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -32,12 +34,10 @@ require (
 	go.elastic.co/fastjson v1.5.1 // indirect
 	golang.org/x/crypto v0.46.0 // indirect
 	golang.org/x/lint v0.0.0-20201208152925-83fdc39ff7b5 // indirect
-	golang.org/x/mod v0.30.0 // indirect
 	golang.org/x/net v0.48.0 // indirect
 	golang.org/x/sync v0.19.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/telemetry v0.0.0-20251111182119-bc8e575c7b54 // indirect
 	golang.org/x/text v0.32.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	howett.net/plist v0.0.0-20181124034731-591f970eefbb // indirect
 )
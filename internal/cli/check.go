@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/gen"
+)
+
+var checkCommand = Command{
+	Name:  "check",
+	Short: "report wrappers which are missing or out of date, without writing them",
+	Long: "check compares the source hash of the wrappers generate would write for the same flags with the one in\n" +
+		"the header of the files on disk, without rendering them. it fails when one is missing or differs, so CI\n" +
+		"can catch a forgotten go generate. wrappers of plugins are rendered and compared whole. wrappers\n" +
+		"generated from the file whose target is gone are reported as orphans.",
+	Run: runCheck,
+}
+
+func runCheck(app *App, args []string) error {
+	cfg := app.newConfig()
+	if err := cfg.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.Resolve(); err != nil {
+		return err
+	}
+	opts := genOptions(cfg)
+	model, err := gen.Load(opts)
+	if err != nil {
+		return err
+	}
+	files, err := gen.Check(model, opts)
+	if err != nil {
+		return err
+	}
+	keep := make([]string, 0, len(files))
+	for _, file := range files {
+		keep = append(keep, file.Path)
+	}
+	orphans, err := gen.Orphans(model, opts, keep)
+	if err != nil {
+		return err
+	}
+
+	var outdated int
+	for _, file := range files {
+		switch file.Status {
+		case gen.StatusCreate:
+			fmt.Fprintf(app.Stdout, "missing %s\n", file.Path)
+			outdated++
+		case gen.StatusModify:
+			fmt.Fprintf(app.Stdout, "stale   %s\n", file.Path)
+			outdated++
+		}
+	}
+	for _, orphan := range orphans {
+		fmt.Fprintf(app.Stdout, "orphan  %s\n", orphan)
+		outdated++
+	}
+	if outdated > 0 {
+		return fmt.Errorf("%d of %d wrappers are out of date, run %s generate", outdated, len(files)+len(orphans), app.Name)
+	}
+	fmt.Fprintf(app.Stdout, "%d wrappers are up to date\n", len(files))
+	return nil
+}
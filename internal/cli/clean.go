@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/internal/config"
+	"github.com/pm1381/sirish/internal/visitors"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+)
+
+var cleanCommand = Command{
+	Name:  "clean",
+	Short: "remove the files sirish generated",
+	Long: "clean removes every go file under -dir starting with the header sirish writes, whatever its name,\n" +
+		"so wrappers of a renamed suffix or output directory are removed too.",
+	Run: runClean,
+}
+
+func runClean(app *App, args []string) error {
+	flagSet := app.newFlagSet()
+	dir := flagSet.String("dir", ".", "directory to clean, its subdirectories included")
+	dryRun := flagSet.Bool("n", false, "print the files which would be removed without removing them")
+	if err := config.ParseFlags(flagSet, args); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+	files, err := goFiles(root)
+	if err != nil {
+		return err
+	}
+
+	var removed int
+	for _, file := range files {
+		generated, err := isGenerated(file)
+		if err != nil {
+			return err
+		}
+		if !generated {
+			continue
+		}
+		removed++
+		if *dryRun {
+			fmt.Fprintf(app.Stdout, "would remove %s\n", relative(root, file))
+			continue
+		}
+		if err = os.Remove(file); err != nil {
+			return err
+		}
+		fmt.Fprintf(app.Stdout, "removed %s\n", relative(root, file))
+	}
+	if removed == 0 {
+		fmt.Fprintln(app.Stdout, "no generated files found")
+	}
+	return nil
+}
+
+// isGenerated reads the header of the go file at p, up to its package clause
+func isGenerated(p string) (bool, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), p, nil, parser.ParseComments|parser.PackageClauseOnly)
+	if err != nil {
+		return false, err
+	}
+	return visitors.IsGenerated(file), nil
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/pm1381/sirish/internal/config"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Command is a subcommand of sirish, like generate or check
+type Command struct {
+	Name  string
+	Short string // one line shown in the list of commands
+	Long  string // help text shown above the flags of the command
+	Run   func(app *App, args []string) error
+}
+
+// App runs the sirish commands. go:generate directives call it without one, so generate is the default
+type App struct {
+	Name   string
+	Env    string // production exits on flag errors, other environments return them
+	Stdout io.Writer
+	Stderr io.Writer
+	Banner func() string // shown by generate unless -banner=false
+
+	command Command // the running command
+}
+
+// Commands lists every sirish command in the order help shows them
+func Commands() []Command {
+	return []Command{
+		generateCommand,
+		checkCommand,
+		listCommand,
+		cleanCommand,
+		inspectCommand,
+		configCommand,
+	}
+}
+
+// Run runs the command named by the first argument, or generate when it names none
+func (app *App) Run(args []string) error {
+	command := generateCommand
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			if len(args) > 1 {
+				return app.Run([]string{args[1], "-h"})
+			}
+			app.usage()
+			return nil
+		}
+		if !strings.HasPrefix(args[0], "-") {
+			name := args[0]
+			command, args = Command{}, args[1:]
+			for _, each := range Commands() {
+				if each.Name == name {
+					command = each
+				}
+			}
+			if command.Run == nil {
+				return fmt.Errorf("unknown command %q, run %s help", name, app.Name)
+			}
+		}
+	}
+	app.command = command
+	err := command.Run(app, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil // the usage is already printed
+	}
+	return err
+}
+
+func (app *App) usage() {
+	fmt.Fprintf(app.Stderr, "usage: %s [command] [flags]\n\ncommands:\n", app.Name)
+	tw := tabwriter.NewWriter(app.Stderr, 0, 4, 2, ' ', 0)
+	for _, each := range Commands() {
+		fmt.Fprintf(tw, "  %s\t%s\n", each.Name, each.Short)
+	}
+	tw.Flush()
+	fmt.Fprintf(app.Stderr, "\nwithout a command, generate runs. %s help <command> shows its flags\n", app.Name)
+}
+
+// newConfig is the config of the running command with the flags shared by every generating command
+func (app *App) newConfig() *config.Config {
+	cfg := config.NewConfig(app.Name+" "+app.command.Name, app.Env)
+	app.setUsage(cfg.FlagSet())
+	return cfg
+}
+
+// newFlagSet is the flag set of a running command which does not generate, parse it with config.ParseFlags so
+// its flags have their SIRISH_ variables
+func (app *App) newFlagSet() *flag.FlagSet {
+	flagSet := flag.NewFlagSet(app.Name+" "+app.command.Name, flag.ContinueOnError)
+	if app.Env == "production" {
+		flagSet.Init(flagSet.Name(), flag.ExitOnError)
+	}
+	app.setUsage(flagSet)
+	return flagSet
+}
+
+// setUsage prints the help text of the running command above its flags
+func (app *App) setUsage(flagSet *flag.FlagSet) {
+	command := app.command
+	flagSet.SetOutput(app.Stderr)
+	flagSet.Usage = func() {
+		fmt.Fprintf(app.Stderr, "usage: %s %s [flags]\n\n%s\n\nflags:\n", app.Name, command.Name, command.Long)
+		flagSet.PrintDefaults()
+	}
+}
@@ -0,0 +1,396 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pm1381/sirish/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestApp() (*App, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	return &App{Name: "sirish", Env: "dev", Stdout: stdout, Stderr: stderr}, stdout, stderr
+}
+
+// copySample copies a sample into a temporary directory, so generated files do not end up in the repository
+func copySample(t *testing.T, name string) string {
+	src, err := os.ReadFile(filepath.Join("test_samples", name))
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), src, 0o644))
+	return dir
+}
+
+// sampleModule copies a sample into a temporary module and runs the test there, so the go command formatting
+// the imports of its wrappers resolves them in that module instead of writing to the go.sum of this one
+func sampleModule(t *testing.T, name string) string {
+	dir := copySample(t, name)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0o644))
+	t.Chdir(dir)
+	return dir
+}
+
+func TestRun_Help(t *testing.T) {
+	app, _, stderr := newTestApp()
+	require.NoError(t, app.Run([]string{"help"}))
+	for _, command := range Commands() {
+		assert.Contains(t, stderr.String(), "  "+command.Name+" ")
+	}
+
+	app, _, stderr = newTestApp()
+	require.NoError(t, app.Run([]string{"help", "clean"}))
+	assert.Contains(t, stderr.String(), "usage: sirish clean [flags]")
+	assert.Contains(t, stderr.String(), "-dir")
+	assert.Contains(t, stderr.String(), "[$SIRISH_DIR]")
+	assert.Contains(t, stderr.String(), "[$SIRISH_DRY_RUN]")
+	assert.NotContains(t, stderr.String(), "-tg")
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	app, _, _ := newTestApp()
+	err := app.Run([]string{"generat"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown command "generat"`)
+}
+
+func TestCommands_Lifecycle(t *testing.T) {
+	dir := copySample(t, "store.go")
+	file := filepath.Join(dir, "store.go")
+	generated := filepath.Join(dir, "store.sirish.go")
+
+	app, stdout, _ := newTestApp()
+	err := app.Run([]string{"check", "-f", file})
+	require.Error(t, err)
+	assert.Contains(t, stdout.String(), "missing "+generated)
+
+	// without a command generate runs, like go:generate does
+	app, _, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"-f", file, "-banner=false"}))
+	require.FileExists(t, generated)
+
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"check", "-f", file}))
+	assert.Contains(t, stdout.String(), "1 wrappers are up to date")
+
+	require.NoError(t, os.WriteFile(generated, []byte("// Code generated by github.com/pm1381/sirish. DO NOT EDIT.\n\npackage test_samples\n"), 0o644))
+	app, stdout, _ = newTestApp()
+	require.Error(t, app.Run([]string{"check", "-f", file}))
+	assert.Contains(t, stdout.String(), "stale   "+generated)
+
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"list", "-dir", dir}))
+	assert.Regexp(t, `ProfileStore\s+store.go\s+2\s+store.sirish.go`, stdout.String())
+
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"clean", "-dir", dir, "-n"}))
+	assert.Contains(t, stdout.String(), "would remove store.sirish.go")
+	require.FileExists(t, generated)
+
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"clean", "-dir", dir}))
+	assert.Contains(t, stdout.String(), "removed store.sirish.go")
+	assert.NoFileExists(t, generated)
+	assert.FileExists(t, file)
+}
+
+func TestClean_Env(t *testing.T) {
+	dir := copySample(t, "store.go")
+	app, _, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", filepath.Join(dir, "store.go"), "-banner=false"}))
+	generated := filepath.Join(dir, "store.sirish.go")
+	require.FileExists(t, generated)
+
+	t.Setenv("SIRISH_DIR", dir)
+	t.Setenv("SIRISH_DRY_RUN", "true")
+	app, stdout, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"clean"}))
+	assert.Contains(t, stdout.String(), "would remove store.sirish.go")
+	require.FileExists(t, generated)
+
+	// the flags win over their variables
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"clean", "-n=false"}))
+	assert.Contains(t, stdout.String(), "removed store.sirish.go")
+	assert.NoFileExists(t, generated)
+}
+
+func TestList_ReportsFailingFiles(t *testing.T) {
+	dir := copySample(t, "store.go")
+	broken := filepath.Join(dir, "broken")
+	require.NoError(t, os.MkdirAll(broken, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(broken, "broken.go"), []byte("package broken\n\n// sirish:struct Missing\ntype Present struct{}\n"), 0o644))
+	// prose mentioning a directive is not one
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "doc.go"), []byte("package test_samples\n\n// Doc says types marked with //sirish: are wrapped\ntype Doc struct{}\n"), 0o644))
+
+	app, stdout, stderr := newTestApp()
+	err := app.Run([]string{"list", "-dir", dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 3 files failed to load")
+	assert.Contains(t, stderr.String(), "struct Missing is not declared")
+	assert.Regexp(t, `ProfileStore\s+store.go\s+2\s+store.sirish.go`, stdout.String())
+}
+
+func TestInspect(t *testing.T) {
+	app, stdout, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"inspect", "-f", filepath.Join("test_samples", "store.go")}))
+	for _, want := range []string{
+		"ProfileStore (interface)",
+		`import:  context "context"`,
+		"method:  Get(",
+		"[ctx, error]",
+	} {
+		assert.Contains(t, stdout.String(), want)
+	}
+}
+
+func TestInspect_JSON(t *testing.T) {
+	app, stdout, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"inspect", "-json", "-f", filepath.Join("test_samples", "store.go")}))
+
+	// tools read the documented names, not the go field names
+	var document struct {
+		SchemaVersion int               `json:"schema_version"`
+		File          string            `json:"file"`
+		Imports       map[string]string `json:"imports"`
+		Interfaces    []struct {
+			Name     string `json:"name"`
+			Position struct {
+				File string `json:"file"`
+				Line int    `json:"line"`
+			} `json:"position"`
+			Methods []struct {
+				Name     string `json:"name"`
+				HasCtx   bool   `json:"has_ctx"`
+				Position struct {
+					Line   int `json:"line"`
+					Column int `json:"column"`
+				} `json:"position"`
+			} `json:"methods"`
+		} `json:"interfaces"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &document))
+	assert.Equal(t, InspectSchemaVersion, document.SchemaVersion)
+	assert.Equal(t, map[string]string{"context": "context"}, document.Imports)
+	require.Len(t, document.Interfaces, 1)
+	profileStore := document.Interfaces[0]
+	assert.Equal(t, "ProfileStore", profileStore.Name)
+	assert.Equal(t, document.File, profileStore.Position.File)
+	assert.Equal(t, 11, profileStore.Position.Line)
+	require.Len(t, profileStore.Methods, 2)
+	assert.Equal(t, "Get", profileStore.Methods[0].Name)
+	assert.True(t, profileStore.Methods[0].HasCtx)
+	assert.Equal(t, 12, profileStore.Methods[0].Position.Line)
+	assert.Equal(t, 2, profileStore.Methods[0].Position.Column)
+
+	// names the templates use for their own variables are no part of the schema
+	for _, scratch := range []string{`"locals"`, `"params_overall_names"`, `"result_overall_names"`, `"params_names"`, `"pkgs"`, `"span_name"`} {
+		assert.NotContains(t, stdout.String(), scratch)
+	}
+}
+
+func TestGenerate_DryRun(t *testing.T) {
+	dir := copySample(t, "store.go")
+	file := filepath.Join(dir, "store.go")
+	generated := filepath.Join(dir, "store.sirish.go")
+
+	app, stdout, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-dry-run", "-f", file, "-banner=false"}))
+	assert.Contains(t, stdout.String(), "create    "+generated)
+	assert.NoFileExists(t, generated)
+
+	app, _, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-dry-run", "-f", file, "-banner=false"}))
+	assert.Contains(t, stdout.String(), "unchanged "+generated)
+
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-dry-run", "-f", file, "-banner=false", "-span-type", "db"}))
+	assert.Contains(t, stdout.String(), "modify    "+generated)
+}
+
+func TestGenerate_Output(t *testing.T) {
+	dir := copySample(t, "store.go")
+	file := filepath.Join(dir, "store.go")
+
+	app, stdout, stderr := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-output", "-", "-f", file, "-banner=false"}))
+	assert.Contains(t, stdout.String(), "// sirish: "+filepath.Join(dir, "store.sirish.go"))
+	assert.Contains(t, stdout.String(), "type ProfileStoreSirishWrapperImpl struct")
+	assert.Contains(t, stderr.String(), "sirish starts the firework...")
+	assert.NoFileExists(t, filepath.Join(dir, "store.sirish.go"))
+
+	app, _, _ = newTestApp()
+	err := app.Run([]string{"generate", "-output", filepath.Join(dir, "out.rar"), "-f", file, "-banner=false"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "want -, a .tar or a .zip file")
+}
+
+func TestGenerate_KeepsUnchangedFiles(t *testing.T) {
+	dir := copySample(t, "store.go")
+	file := filepath.Join(dir, "store.go")
+	generated := filepath.Join(dir, "store.sirish.go")
+
+	app, _, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	old := time.Unix(1000, 0)
+	require.NoError(t, os.Chtimes(generated, old, old))
+
+	app, stdout, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	assert.Contains(t, stdout.String(), "- sirish for interface ProfileStore in directory "+dir+" is up to date")
+	info, err := os.Stat(generated)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(old))
+}
+
+func TestCheck_SourceHash(t *testing.T) {
+	dir := copySample(t, "store.go")
+	file := filepath.Join(dir, "store.go")
+	generated := filepath.Join(dir, "store.sirish.go")
+
+	app, _, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	src, err := os.ReadFile(generated)
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "\n// sirish-hash: ")
+
+	// the header alone tells the options changed
+	app, stdout, _ := newTestApp()
+	require.Error(t, app.Run([]string{"check", "-f", file, "-span-type", "db"}))
+	assert.Contains(t, stdout.String(), "stale   "+generated)
+
+	// a wrapper edited below its header keeps its hash, -force renders it again
+	edited := append(src, []byte("\n// edited\n")...)
+	require.NoError(t, os.WriteFile(generated, edited, 0o644))
+	app, _, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	current, err := os.ReadFile(generated)
+	require.NoError(t, err)
+	assert.Equal(t, edited, current)
+
+	app, _, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-force", "-f", file, "-banner=false"}))
+	current, err = os.ReadFile(generated)
+	require.NoError(t, err)
+	assert.Equal(t, src, current)
+}
+
+func TestGenerate_RemovesOrphans(t *testing.T) {
+	dir := copySample(t, "store.go")
+	file := filepath.Join(dir, "store.go")
+	app, _, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	orphan := filepath.Join(dir, "store.sirish.go")
+	require.FileExists(t, orphan)
+
+	// a second target gives every wrapper its own file, store.sirish.go is not generated anymore
+	src, err := os.ReadFile(file)
+	require.NoError(t, err)
+	src = append(src, []byte("\n// sirish:ProfileCache\ntype ProfileCache interface {\n\tDrop(ctx context.Context, id string) error\n}\n")...)
+	require.NoError(t, os.WriteFile(file, src, 0o644))
+	// neither files without the header nor wrappers of other files are touched
+	handWritten := filepath.Join(dir, "store_cache.sirish.go")
+	require.NoError(t, os.WriteFile(handWritten, []byte("package test_samples\n"), 0o644))
+	other := filepath.Join(dir, "other.sirish.go")
+	require.NoError(t, os.WriteFile(other, []byte("// Code generated by github.com/pm1381/sirish. DO NOT EDIT.\n// sirish-source: other.go\n\npackage test_samples\n"), 0o644))
+
+	app, stdout, _ := newTestApp()
+	require.Error(t, app.Run([]string{"check", "-f", file}))
+	assert.Contains(t, stdout.String(), "orphan  "+orphan)
+
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-dry-run", "-f", file, "-banner=false"}))
+	assert.Contains(t, stdout.String(), "remove    "+orphan)
+	assert.FileExists(t, orphan)
+
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	assert.Contains(t, stdout.String(), "- removed orphaned sirish "+orphan)
+	assert.NoFileExists(t, orphan)
+	assert.FileExists(t, filepath.Join(dir, "ProfileStore.store.sirish.go"))
+	assert.FileExists(t, filepath.Join(dir, "ProfileCache.store.sirish.go"))
+	assert.FileExists(t, handWritten)
+	assert.FileExists(t, other)
+
+	app, _, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"check", "-f", file}))
+}
+
+func TestGenerate_KeepsWrappersOfOtherDirectives(t *testing.T) {
+	dir := sampleModule(t, "store.go")
+	file := filepath.Join(dir, "store.go")
+	src, err := os.ReadFile(file)
+	require.NoError(t, err)
+	src = append(src, []byte("\ntype ProfileCache interface {\n\tDrop(ctx context.Context, id string) error\n}\n")...)
+	require.NoError(t, os.WriteFile(file, src, 0o644))
+
+	// two go:generate lines of store.go, the marked target next to it and ProfileCache into tracing
+	app, _, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	first := filepath.Join(dir, "store.sirish.go")
+	require.FileExists(t, first)
+	second := []string{"generate", "-f", file, "-t", "ProfileCache", "-out-dir", "tracing", "-banner=false"}
+	app, stdout, _ := newTestApp()
+	require.NoError(t, app.Run(second))
+	assert.NotContains(t, stdout.String(), "removed")
+	assert.FileExists(t, first)
+	assert.FileExists(t, filepath.Join(dir, "tracing", "ProfileCache.store.sirish.go"))
+
+	app, _, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"check", "-f", file}))
+	app, _, _ = newTestApp()
+	require.NoError(t, app.Run(append([]string{"check"}, second[1:len(second)-1]...)))
+
+	// the first line lost its target, only its own wrapper is removed
+	require.NoError(t, os.WriteFile(file, bytes.Replace(src, []byte("// sirish:ProfileStore"), nil, 1), 0o644))
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-banner=false"}))
+	assert.Contains(t, stdout.String(), "- removed orphaned sirish "+first)
+	assert.NoFileExists(t, first)
+	assert.FileExists(t, filepath.Join(dir, "tracing", "ProfileCache.store.sirish.go"))
+}
+
+func TestGenerate_RemovesRenamedTypeTargets(t *testing.T) {
+	dir := copySample(t, "store.go")
+	file := filepath.Join(dir, "store.go")
+	src, err := os.ReadFile(file)
+	require.NoError(t, err)
+	shadow := append(src, []byte("\ntype Shadow interface {\n\tDrop(ctx context.Context, id string) error\n}\n")...)
+	require.NoError(t, os.WriteFile(file, shadow, 0o644))
+	app, _, _ := newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-t", "Shadow", "-banner=false"}))
+	old := filepath.Join(dir, "Shadow.store.sirish.go")
+	require.FileExists(t, old)
+
+	// the interface and the -t of its directive are renamed together
+	require.NoError(t, os.WriteFile(file, bytes.ReplaceAll(shadow, []byte("Shadow"), []byte("Shade")), 0o644))
+	app, stdout, _ := newTestApp()
+	require.Error(t, app.Run([]string{"check", "-f", file, "-t", "Shade"}))
+	assert.Contains(t, stdout.String(), "orphan  "+old)
+
+	app, stdout, _ = newTestApp()
+	require.NoError(t, app.Run([]string{"generate", "-f", file, "-t", "Shade", "-banner=false"}))
+	assert.Contains(t, stdout.String(), "- removed orphaned sirish "+old)
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, filepath.Join(dir, "Shade.store.sirish.go"))
+	assert.FileExists(t, filepath.Join(dir, "ProfileStore.store.sirish.go"))
+}
+
+func TestGenOptions_FlagsWinOverInterfaces(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, config.FileName), []byte("interfaces:\n  Store:\n    tg: true\n    closers: true\n"), 0o644))
+	cfg := config.NewConfig("sirish", "dev")
+	require.NoError(t, cfg.Parse([]string{"-f", filepath.Join(dir, "store.go"), "-tg=false"}))
+	require.NoError(t, cfg.Resolve())
+
+	opts := genOptions(cfg)
+	assert.False(t, opts.Interfaces["Store"].CreateTx, "-tg=false wins over interfaces.Store.tg")
+	assert.True(t, opts.Interfaces["Store"].Closers, "closers is not given, the interface sets it")
+}
@@ -0,0 +1,20 @@
+package cli
+
+var configCommand = Command{
+	Name:  "config",
+	Short: "print the configuration merged from flags, SIRISH_* variables and .sirish.yaml",
+	Long: "config prints the options generate would use for the file given by -f or GOFILE, as a .sirish.yaml,\n" +
+		"naming the config file they were read from.",
+	Run: runConfig,
+}
+
+func runConfig(app *App, args []string) error {
+	cfg := app.newConfig()
+	if err := cfg.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.Resolve(); err != nil {
+		return err
+	}
+	return cfg.WriteEffective(app.Stdout)
+}
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pm1381/sirish/gen"
+	"github.com/pm1381/sirish/internal/config"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var generateCommand = Command{
+	Name:  "generate",
+	Short: "write the wrappers of the interfaces of a file, the default command",
+	Long: "generate writes a traced wrapper for every interface of the file given by -f or GOFILE which is\n" +
+		"marked with //sirish:Name or named by -t. go:generate directives run it without naming it.\n" +
+		"-dry-run prints which files would be created, modified or left unchanged and writes nothing,\n" +
+		"-output prints the files with - or packs them in a .tar or .zip archive instead of writing them.\n" +
+		"wrappers generated from the file whose target is gone are removed, -dry-run prints them instead.\n" +
+		"files without the header sirish writes are never removed.",
+	Run: runGenerate,
+}
+
+func runGenerate(app *App, args []string) error {
+	cfg := app.newConfig()
+	dryRun := cfg.FlagSet().Bool("dry-run", false, "print which files would be created, modified or left unchanged, write nothing")
+	force := cfg.FlagSet().Bool("force", false, "render every wrapper, even the ones whose header holds their current source hash")
+	output := cfg.FlagSet().String("output", "", "- prints the files, a .tar or .zip path archives them. empty writes them next to their sources")
+	if err := cfg.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.Resolve(); err != nil {
+		return err
+	}
+	// the files own stdout when they are printed
+	progress := app.Stdout
+	if *output == "-" {
+		progress = app.Stderr
+	}
+	if *cfg.ShowBanner && app.Banner != nil {
+		fmt.Fprint(progress, app.Banner())
+	}
+	fmt.Fprintf(progress, "sirish configs: filePath: %s goPackage: %s \n", *cfg.FilePath, cfg.GoPackage)
+
+	opts := genOptions(cfg)
+	opts.Force = *force
+	model, err := gen.Load(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(progress, "sirish starts the firework...")
+	files, err := gen.Generate(model, opts)
+	if err != nil {
+		return err
+	}
+	sink, err := newSink(app, *output, *dryRun)
+	if err != nil {
+		return err
+	}
+	if err = gen.Write(sink, files); err != nil {
+		return err
+	}
+	if *output != "" {
+		return nil // the files on disk are left as they are
+	}
+	return removeOrphans(app, model, opts, files, *dryRun)
+}
+
+// removeOrphans removes the wrappers generated from the file of model whose targets are gone
+func removeOrphans(app *App, model *gen.Model, opts gen.Options, files []gen.File, dryRun bool) error {
+	keep := make([]string, 0, len(files))
+	for _, file := range files {
+		keep = append(keep, file.Path)
+	}
+	orphans, err := gen.Orphans(model, opts, keep)
+	if err != nil {
+		return err
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	for _, orphan := range orphans {
+		if dryRun {
+			// named like the dry run sink names the files it would write
+			p := orphan
+			if rel := relative(root, orphan); !strings.HasPrefix(rel, "..") {
+				p = rel
+			}
+			fmt.Fprintf(app.Stdout, "%-9s %s\n", "remove", p)
+			continue
+		}
+		if err = os.Remove(orphan); err != nil {
+			return err
+		}
+		fmt.Fprintf(app.Stdout, "- removed orphaned sirish %s \n", orphan)
+	}
+	return nil
+}
+
+// newSink is where generate writes the files: disk by default, stdout or an archive for -output and
+// nowhere for -dry-run. archives and dry runs name the files relative to the working directory
+func newSink(app *App, output string, dryRun bool) (gen.Sink, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case dryRun && output != "":
+		return nil, errors.New("-dry-run and -output can not be used together")
+	case dryRun:
+		return gen.NewDryRunSink(app.Stdout, root), nil
+	case output == "":
+		return progressSink{Sink: gen.NewDiskSink(), w: app.Stdout}, nil
+	case output == "-":
+		return gen.NewWriterSink(app.Stdout), nil
+	}
+	var newArchive func(w io.Writer, root string) gen.Sink
+	switch filepath.Ext(output) {
+	case ".tar":
+		newArchive = gen.NewTarSink
+	case ".zip":
+		newArchive = gen.NewZipSink
+	default:
+		return nil, fmt.Errorf("-output %s: want -, a .tar or a .zip file", output)
+	}
+	archive, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	return closingSink{Sink: newArchive(archive, root), closer: archive}, nil
+}
+
+// progressSink reports every file before writing it
+type progressSink struct {
+	gen.Sink
+	w io.Writer
+}
+
+func (ps progressSink) Write(file gen.File) error {
+	status, err := gen.Compare(file)
+	if err != nil {
+		return err
+	}
+	if status == gen.StatusUnchanged {
+		fmt.Fprintf(ps.w, "- sirish for interface %s in directory %s is up to date \n", file.Interface, filepath.Dir(file.Path))
+	} else {
+		fmt.Fprintf(ps.w, "- generating sirish for interface %s in directory %s \n", file.Interface, filepath.Dir(file.Path))
+	}
+	return ps.Sink.Write(file)
+}
+
+// closingSink closes the archive file once the archive is written to it
+type closingSink struct {
+	gen.Sink
+	closer io.Closer
+}
+
+func (cs closingSink) Close() error {
+	err := cs.Sink.Close()
+	if closeErr := cs.closer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// genOptions are the options of cfg for the gen package, interfaces of the config file get their own
+// options on top of the general ones unless a flag or variable set them explicitly
+func genOptions(cfg *config.Config) gen.Options {
+	general := gen.WrapperOptions{
+		Version:   "",
+		Imports:   *cfg.FormatImports,
+		CreateTx:  *cfg.TraceGenerator,
+		Streams:   *cfg.StreamSpans,
+		Closers:   *cfg.CloserSpans,
+		Callbacks: *cfg.CallbackSpans,
+		SpanType:  *cfg.SpanType,
+		Labels:    *cfg.Labels,
+	}
+	overrides := cfg.InterfaceOverrides()
+	perInterface := make(map[string]gen.WrapperOptions, len(overrides))
+	for name, override := range overrides {
+		opts := general
+		opts.Labels = override.MergeLabels(*cfg.Labels)
+		if override.Tg != nil {
+			opts.CreateTx = *override.Tg
+		}
+		if override.Stream != nil {
+			opts.Streams = *override.Stream
+		}
+		if override.Closers != nil {
+			opts.Closers = *override.Closers
+		}
+		if override.Callbacks != nil {
+			opts.Callbacks = *override.Callbacks
+		}
+		if override.SpanType != nil {
+			opts.SpanType = *override.SpanType
+		}
+		perInterface[name] = opts
+	}
+	return gen.Options{
+		File:       *cfg.FilePath,
+		Types:      *cfg.Types,
+		OutDir:     *cfg.OutDir,
+		OutPkg:     *cfg.OutPkg,
+		Suffix:     *cfg.Suffix,
+		Backend:    *cfg.Backend,
+		Template:   *cfg.Template,
+		Wrapper:    general,
+		Interfaces: perInterface,
+		Jobs:       *cfg.Jobs,
+	}
+}
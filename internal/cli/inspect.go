@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pm1381/sirish/gen"
+	"io"
+	"sort"
+	"strings"
+)
+
+var inspectCommand = Command{
+	Name:  "inspect",
+	Short: "print the interfaces parsed from a file as the templates see them",
+	Long: "inspect parses the file given by -f or GOFILE like generate does and prints the model the wrapper\n" +
+		"templates are executed with, without rendering or writing anything. -json prints it for other tools,\n" +
+		"with field names kept stable across releases of the same schema_version.",
+	Run: runInspect,
+}
+
+// InspectSchemaVersion is the schema_version of inspect -json. it is bumped whenever a field is removed or
+// changes meaning, new fields keep it
+const InspectSchemaVersion = 1
+
+// InspectDocument is what inspect -json prints
+type InspectDocument struct {
+	SchemaVersion int             `json:"schema_version"`
+	File          string          `json:"file"`
+	Imports       gen.Imports     `json:"imports"` // import path to name of every import of the file
+	Interfaces    []gen.Interface `json:"interfaces"`
+}
+
+func runInspect(app *App, args []string) error {
+	cfg := app.newConfig()
+	asJSON := cfg.FlagSet().Bool("json", false, "print the model as JSON")
+	if err := cfg.Parse(args); err != nil {
+		return err
+	}
+	if err := cfg.Resolve(); err != nil {
+		return err
+	}
+	model, err := gen.Load(genOptions(cfg))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		interfaces := model.Interfaces
+		if interfaces == nil {
+			interfaces = []gen.Interface{}
+		}
+		encoder := json.NewEncoder(app.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(InspectDocument{
+			SchemaVersion: InspectSchemaVersion,
+			File:          model.File,
+			Imports:       model.Imports,
+			Interfaces:    interfaces,
+		})
+	}
+	for i, info := range model.Interfaces {
+		if i > 0 {
+			fmt.Fprintln(app.Stdout)
+		}
+		writeInterface(app.Stdout, info, *cfg.Suffix)
+	}
+	return nil
+}
+
+// writeInterface prints info in a form meant for people, one method per line
+func writeInterface(w io.Writer, info gen.Interface, suffix string) {
+	kind := "interface"
+	switch {
+	case info.Func:
+		kind = "function type"
+	case info.Struct != "":
+		kind = "interface of struct " + info.Struct
+	}
+	fmt.Fprintf(w, "%s%s (%s)\n", info.Name, typeParams(info.TypeParams), kind)
+	fmt.Fprintf(w, "  source:  %s:%d, package %s\n", info.FilePath, info.Position.Line, info.Package)
+	fmt.Fprintf(w, "  output:  %s, package %s\n", gen.OutputPath(info, suffix), info.OutPackage)
+
+	importPaths := make([]string, 0, len(info.Imports))
+	for importPath := range info.Imports {
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+	for _, importPath := range importPaths {
+		fmt.Fprintf(w, "  import:  %s %q\n", info.Imports[importPath], importPath)
+	}
+	for _, method := range info.Methods {
+		fmt.Fprintf(w, "  method:  %s%s\n", method.Name, signature(method))
+	}
+}
+
+func typeParams(params []gen.TypeParam) string {
+	if len(params) == 0 {
+		return ""
+	}
+	list := make([]string, 0, len(params))
+	for _, param := range params {
+		list = append(list, param.Name+" "+param.Constraint)
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+// signature prints the params and results of method followed by what its wrapper traces
+func signature(method gen.Method) string {
+	params := make([]string, 0, len(method.Params))
+	var traits []string
+	if method.HasCtx {
+		traits = append(traits, "ctx")
+	}
+	for _, param := range method.Params {
+		params = append(params, strings.TrimSpace(param.Name+" "+param.Type))
+		if param.Callback != nil {
+			traits = append(traits, "callback "+param.Name)
+		}
+	}
+	results := make([]string, 0, len(method.Results))
+	for _, result := range method.Results {
+		results = append(results, strings.TrimSpace(result.Name+" "+result.Type))
+	}
+	if method.HasError {
+		traits = append(traits, "error")
+	}
+	if method.Stream != nil {
+		traits = append(traits, "stream "+method.Stream.Kind)
+	}
+	if method.Closer != nil {
+		traits = append(traits, "closer "+method.Closer.Kind)
+	}
+
+	res := "(" + strings.Join(params, ", ") + ")"
+	switch {
+	case len(results) == 1 && method.Results[0].Name == "":
+		res += " " + results[0]
+	case len(results) > 0:
+		res += " (" + strings.Join(results, ", ") + ")"
+	}
+	if len(traits) > 0 {
+		res += "  [" + strings.Join(traits, ", ") + "]"
+	}
+	return res
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/gen"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+var listCommand = Command{
+	Name:  "list",
+	Short: "list the annotated interfaces of a module with their method counts and output paths",
+	Long: "list finds every interface and struct marked with //sirish: under -dir and shows how many methods\n" +
+		"its wrapper traces and where generate writes it, using the output flags and config file of generate.",
+	Run: runList,
+}
+
+func runList(app *App, args []string) error {
+	cfg := app.newConfig()
+	dir := cfg.FlagSet().String("dir", ".", "directory to search, its subdirectories included")
+	if err := cfg.Parse(args); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+	*cfg.FilePath = filepath.Join(root, "sirish.go") // only used to find the config file
+	if err = cfg.Resolve(); err != nil {
+		return err
+	}
+	files, err := goFiles(root)
+	if err != nil {
+		return err
+	}
+
+	var sources []string
+	for _, file := range files {
+		if !strings.HasSuffix(file, "."+*cfg.Suffix+".go") {
+			sources = append(sources, file)
+		}
+	}
+	// files without marked targets load to an empty model, -j of them are parsed at once
+	opts := genOptions(cfg)
+	opts.Types = nil
+	// a file failing to load is reported, the ones which loaded are still listed
+	models, loadErr := gen.LoadAll(opts, sources)
+	failed := 0
+	if loadErr != nil {
+		fmt.Fprintln(app.Stderr, loadErr)
+	}
+
+	tw := tabwriter.NewWriter(app.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "INTERFACE\tFILE\tMETHODS\tOUTPUT")
+	for _, model := range models {
+		if model == nil {
+			failed++
+			continue
+		}
+		for _, info := range model.Interfaces {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", info.QualifiedName, relative(root, model.File), len(info.Methods),
+				relative(root, gen.OutputPath(info, *cfg.Suffix)))
+		}
+	}
+	if err = tw.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to load", failed, len(sources))
+	}
+	return nil
+}
+
+// goFiles lists the go files under root which are not tests, skipping vendor, testdata and hidden directories
+func goFiles(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if p != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func relative(root string, p string) string {
+	if rel, err := filepath.Rel(root, p); err == nil {
+		return rel
+	}
+	return p
+}
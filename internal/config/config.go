@@ -4,15 +4,31 @@ import (
 	"flag"
 	"github.com/pm1381/sirish/internal/dto"
 	"os"
+	"path/filepath"
 )
 
 type Config struct {
 	FormatImports  *bool
 	TraceGenerator *bool
+	StreamSpans    *bool
+	CloserSpans    *bool
+	CallbackSpans  *bool
 	Types          *dto.Types
 	FilePath       *string // Relative Path
+	OutDir         *string // Relative to FilePath directory
+	OutPkg         *string
+	Template       *string // file or directory of templates replacing the embedded one
+	Backend        *string
+	Suffix         *string // suffix of the generated files and types
+	SpanType       *string // span type of wrappers whose constructor gets an empty one
+	Labels         *dto.Labels
+	Interfaces     map[string]InterfaceConfig // per interface overrides of the config file
+	ConfigFile     string                     // path of the config file in use, empty without one
+	sources        map[string]string          // flag name to the flag, variable or config key which set it
+	givenLabels    map[string]bool            // label keys of -label and SIRISH_LABELS
 	GoPackage      string
 	ShowBanner     *bool
+	Jobs           *int // workers loading, rendering and formatting, GOMAXPROCS when 0
 	flagSet        *flag.FlagSet
 }
 
@@ -25,21 +41,68 @@ func NewConfig(name string, env string) *Config {
 		FormatImports:  new(bool),
 		Types:          new(dto.Types),
 		FilePath:       new(string),
+		OutDir:         new(string),
+		OutPkg:         new(string),
+		Template:       new(string),
+		Backend:        new(string),
+		Suffix:         new(string),
+		SpanType:       new(string),
+		Labels:         new(dto.Labels),
 		ShowBanner:     new(bool),
 		TraceGenerator: new(bool),
+		StreamSpans:    new(bool),
+		CloserSpans:    new(bool),
+		CallbackSpans:  new(bool),
+		Jobs:           new(int),
 		flagSet:        fg,
+		sources:        make(map[string]string),
 	}
 	cfg.GoPackage = os.Getenv("GOPACKAGE")
 	cfg.flagSet.BoolVar(cfg.ShowBanner, "banner", true, "Show program version")
 	cfg.flagSet.BoolVar(cfg.FormatImports, "fmt", true, "format imports")
 	cfg.flagSet.BoolVar(cfg.TraceGenerator, "tg", true, "if set to true it will init tracing where the context is not passed")
+	cfg.flagSet.BoolVar(cfg.StreamSpans, "stream", false, "keep spans of channel, iterator and cursor results open until the caller consumes them")
+	cfg.flagSet.BoolVar(cfg.CloserSpans, "closers", false, "keep spans of io closers and other wrapped interfaces results open until they are closed")
+	cfg.flagSet.BoolVar(cfg.CallbackSpans, "callbacks", true, "trace function params receiving a context as child spans of the method span")
 	cfg.flagSet.StringVar(cfg.FilePath, "f", "", "File path to parse. Can be overwritten with GOFILE")
+	cfg.flagSet.StringVar(cfg.OutDir, "out-dir", "", "directory to write wrappers to, relative to the parsed file. defaults to out-pkg next to it or the file directory")
+	cfg.flagSet.StringVar(cfg.OutPkg, "out-pkg", "", "package of the wrappers when they are written into another directory. defaults to the out-dir name")
+	cfg.flagSet.StringVar(cfg.Template, "template", "", "template file, or directory whose wrapper.gotmpl is executed with the other .gotmpl files as partials, replacing the embedded template")
+	cfg.flagSet.StringVar(cfg.Backend, "backend", "apm", "tracing backend of the wrappers, apm or the name of a sirish-gen-<name> plugin on PATH")
+	cfg.flagSet.StringVar(cfg.Suffix, "suffix", "sirish", "suffix of the generated files and wrapper types")
+	cfg.flagSet.StringVar(cfg.SpanType, "span-type", "", "span type used when the wrapper constructor gets an empty one")
+	cfg.flagSet.Var(cfg.Labels, "label", "label set on every span, as key=value. can be repeated")
+	cfg.flagSet.IntVar(cfg.Jobs, "j", 0, "number of files and wrappers loaded, rendered and formatted in parallel. 0 uses GOMAXPROCS")
 	cfg.flagSet.Var(cfg.Types, "t", "list of interfaces which apm will be applied to."+
 		"it can be either comma separated or repeated for example interface1, interface2 or -t interface1 -t interface2")
-
 	return &cfg
 }
 
+// Parse reads the flags, then the SIRISH_* variables of the flags not given. flags commands add
+// to FlagSet get their variables too
 func (c *Config) Parse(arguments []string) error {
-	return c.flagSet.Parse(arguments)
+	documentEnv(c.flagSet)
+	if err := c.flagSet.Parse(arguments); err != nil {
+		return err
+	}
+	return c.applyEnv()
+}
+
+// Resolve makes FilePath absolute, falling back to GOFILE, and loads the config file nearest to it
+func (c *Config) Resolve() error {
+	if *c.FilePath == "" {
+		*c.FilePath = os.Getenv("GOFILE")
+	}
+	abs, err := filepath.Abs(*c.FilePath)
+	if err != nil {
+		return err
+	}
+	*c.FilePath = abs
+	// the nearest .sirish.yaml sets the defaults of the flags not given
+	return c.Load(filepath.Dir(abs))
+}
+
+// FlagSet lets commands add their own flags and usage to the shared ones
+func (c *Config) FlagSet() *flag.FlagSet {
+	return c.flagSet
 }
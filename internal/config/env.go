@@ -0,0 +1,95 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvPrefix starts the environment variable of every flag, e.g. SIRISH_OUT_DIR for -out-dir
+const EnvPrefix = "SIRISH_"
+
+// envNames are the variables of flags whose name alone would be unclear
+var envNames = map[string]string{
+	"f":     EnvPrefix + "FILE",
+	"t":     EnvPrefix + "TYPES",
+	"label": EnvPrefix + "LABELS",
+	"j":     EnvPrefix + "JOBS",
+	"n":     EnvPrefix + "DRY_RUN", // of clean, like -dry-run of generate
+}
+
+// EnvName is the environment variable overriding the flag name
+func EnvName(name string) string {
+	if env, ok := envNames[name]; ok {
+		return env
+	}
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// ParseFlags parses arguments into the flags of a command which are not the ones of a Config, setting the
+// flags not given on the command line from their environment variables like Parse does
+func ParseFlags(flagSet *flag.FlagSet, arguments []string) error {
+	documentEnv(flagSet)
+	if err := flagSet.Parse(arguments); err != nil {
+		return err
+	}
+	return setFromEnv(flagSet, make(map[string]string))
+}
+
+// applyEnv sets the flags not given on the command line from their environment variables
+func (c *Config) applyEnv() error {
+	return setFromEnv(c.flagSet, c.sources)
+}
+
+// setFromEnv sets the flags of flagSet not given on the command line from their environment variables,
+// recording where the value of every set flag comes from in sources. lists like SIRISH_TYPES and
+// SIRISH_LABELS are comma separated
+func setFromEnv(flagSet *flag.FlagSet, sources map[string]string) error {
+	given := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+		sources[f.Name] = "-" + f.Name
+	})
+	var err error
+	flagSet.VisitAll(func(f *flag.Flag) {
+		env := EnvName(f.Name)
+		value, ok := os.LookupEnv(env)
+		if err != nil || given[f.Name] || !ok {
+			return
+		}
+		values := []string{value}
+		if f.Name == "label" {
+			values = strings.Split(value, ",")
+		}
+		for _, each := range values {
+			if err = flagSet.Set(f.Name, each); err != nil {
+				err = fmt.Errorf("%s: %w", env, err)
+				return
+			}
+		}
+		sources[f.Name] = env
+	})
+	return err
+}
+
+// source names where the value of the flag name comes from, for error messages
+func (c *Config) source(name string) string {
+	if source, ok := c.sources[name]; ok {
+		return source
+	}
+	return "default"
+}
+
+// explicit reports whether the flag name was given on the command line or by its environment variable
+func (c *Config) explicit(name string) bool {
+	source := c.sources[name]
+	return source == "-"+name || source == EnvName(name)
+}
+
+// documentEnv adds the environment variable of every flag of flagSet to its usage
+func documentEnv(flagSet *flag.FlagSet) {
+	flagSet.VisitAll(func(f *flag.Flag) {
+		f.Usage += " [$" + EnvName(f.Name) + "]"
+	})
+}
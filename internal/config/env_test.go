@@ -0,0 +1,100 @@
+package config
+
+import (
+	"flag"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"banner":    "SIRISH_BANNER",
+		"out-dir":   "SIRISH_OUT_DIR",
+		"span-type": "SIRISH_SPAN_TYPE",
+		"f":         "SIRISH_FILE",
+		"t":         "SIRISH_TYPES",
+		"label":     "SIRISH_LABELS",
+	}
+	for flagName, want := range tests {
+		assert.Equal(t, want, EnvName(flagName))
+	}
+}
+
+func TestParse_Env(t *testing.T) {
+	t.Setenv("SIRISH_BANNER", "false")
+	t.Setenv("SIRISH_TG", "false")
+	t.Setenv("SIRISH_TYPES", "Repo,ProfileStore")
+	t.Setenv("SIRISH_LABELS", "team=payments,tier=gold")
+	t.Setenv("SIRISH_SUFFIX", "traced")
+	cfg := NewConfig("sirish", "dev")
+
+	require.NoError(t, cfg.Parse([]string{"-tg=true", "-label", "tier=bronze"}))
+	assert.Equal(t, false, *cfg.ShowBanner)
+	assert.Equal(t, true, *cfg.TraceGenerator) // the flag wins
+	assert.ElementsMatch(t, []string{"Repo", "ProfileStore"}, []string(*cfg.Types))
+	assert.Equal(t, dto.Labels{"tier": "bronze"}, *cfg.Labels)
+	assert.Equal(t, "traced", *cfg.Suffix)
+}
+
+func TestParseFlags_Env(t *testing.T) {
+	t.Setenv("SIRISH_DIR", "services")
+	t.Setenv("SIRISH_DRY_RUN", "true")
+	flagSet := flag.NewFlagSet("sirish clean", flag.ContinueOnError)
+	dir := flagSet.String("dir", ".", "directory to clean")
+	dryRun := flagSet.Bool("n", false, "print only")
+
+	require.NoError(t, ParseFlags(flagSet, []string{"-dir", "internal"}))
+	assert.Equal(t, "internal", *dir) // the flag wins
+	assert.True(t, *dryRun)
+	assert.Contains(t, flagSet.Lookup("n").Usage, "[$SIRISH_DRY_RUN]")
+
+	t.Setenv("SIRISH_DRY_RUN", "sometimes")
+	err := ParseFlags(flag.NewFlagSet("sirish clean", flag.ContinueOnError), nil)
+	require.NoError(t, err, "variables of other flags are not read")
+	flagSet = flag.NewFlagSet("sirish clean", flag.ContinueOnError)
+	flagSet.Bool("n", false, "print only")
+	err = ParseFlags(flagSet, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SIRISH_DRY_RUN: ")
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	t.Setenv("SIRISH_SPAN_TYPE", "cache")
+	t.Setenv("SIRISH_LABELS", "tier=silver")
+	dir := writeModule(t, "span_type: db\nsuffix: traced\nlabels:\n  team: payments\n  tier: gold\n")
+	cfg := NewConfig("sirish", "dev")
+	require.NoError(t, cfg.Parse(nil))
+	require.NoError(t, cfg.Load(dir))
+
+	assert.Equal(t, "cache", *cfg.SpanType)
+	assert.Equal(t, "traced", *cfg.Suffix)
+	assert.Equal(t, dto.Labels{"team": "payments", "tier": "silver"}, *cfg.Labels)
+}
+
+func TestEnv_ErrorsNameTheVariable(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   string
+		value string
+		err   string
+	}{
+		{name: "bad bool", env: "SIRISH_BANNER", value: "sometimes", err: "SIRISH_BANNER: "},
+		{name: "bad label", env: "SIRISH_LABELS", value: "team", err: `SIRISH_LABELS: label "team" is not in key=value form`},
+		{name: "unsupported backend", env: "SIRISH_BACKEND", value: "otel", err: `SIRISH_BACKEND: backend "otel" is not supported`},
+		{name: "empty suffix", env: "SIRISH_SUFFIX", value: "", err: "SIRISH_SUFFIX: suffix can not be empty"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(test.env, test.value)
+			cfg := NewConfig("sirish", "dev")
+			err := cfg.Parse(nil)
+			if err == nil {
+				err = cfg.Load(t.TempDir())
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
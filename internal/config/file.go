@@ -0,0 +1,281 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/wrapper"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// FileName is the project config file, the nearest one up to the module root is used
+const FileName = ".sirish.yaml"
+
+// File is the content of a project config file. unset fields keep the flag defaults
+type File struct {
+	Backend    *string                    `yaml:"backend,omitempty"`
+	Suffix     *string                    `yaml:"suffix,omitempty"`
+	Banner     *bool                      `yaml:"banner,omitempty"`
+	Fmt        *bool                      `yaml:"fmt,omitempty"`
+	Tg         *bool                      `yaml:"tg,omitempty"`
+	Stream     *bool                      `yaml:"stream,omitempty"`
+	Closers    *bool                      `yaml:"closers,omitempty"`
+	Callbacks  *bool                      `yaml:"callbacks,omitempty"`
+	Template   *string                    `yaml:"template,omitempty"`
+	SpanType   *string                    `yaml:"span_type,omitempty"`
+	Labels     map[string]string          `yaml:"labels,omitempty"`
+	Output     Output                     `yaml:"output,omitempty"`
+	Interfaces map[string]InterfaceConfig `yaml:"interfaces,omitempty"`
+}
+
+// Output is where the wrappers are written, the same as -out-dir and -out-pkg
+type Output struct {
+	Dir *string `yaml:"dir,omitempty"`
+	Pkg *string `yaml:"pkg,omitempty"`
+}
+
+// InterfaceConfig overrides the options of one wrapped interface, labels are added to the global ones
+type InterfaceConfig struct {
+	Tg        *bool             `yaml:"tg,omitempty"`
+	Stream    *bool             `yaml:"stream,omitempty"`
+	Closers   *bool             `yaml:"closers,omitempty"`
+	Callbacks *bool             `yaml:"callbacks,omitempty"`
+	SpanType  *string           `yaml:"span_type,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// FindFile returns the nearest FileName from dir up to the directory holding go.mod, empty if there is none
+func FindFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		candidate := filepath.Join(dir, FileName)
+		if _, err = os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if _, err = os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return "", nil // the module root is the last directory searched
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// ReadFile decodes a config file, unknown keys are errors so typos do not go unnoticed
+func ReadFile(p string) (*File, error) {
+	src, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	file := new(File)
+	decoder := yaml.NewDecoder(bytes.NewReader(src))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading %s: %w", p, err)
+	}
+	return file, nil
+}
+
+// Load applies the nearest config file of dir to the flags which were not given on the command line
+// or by their environment variables
+func (c *Config) Load(dir string) error {
+	p, err := FindFile(dir)
+	if err != nil {
+		return err
+	}
+	if p != "" {
+		if err = c.apply(p); err != nil {
+			return err
+		}
+	}
+	return c.Validate()
+}
+
+func (c *Config) apply(p string) error {
+	file, err := ReadFile(p)
+	if err != nil {
+		return err
+	}
+	if file.Template != nil && *file.Template != "" && !filepath.IsAbs(*file.Template) {
+		template := filepath.Join(filepath.Dir(p), *file.Template) // relative to the config file, not to the go:generate line
+		file.Template = &template
+	}
+
+	given := make(map[string]bool)
+	c.flagSet.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for _, value := range file.flagValues() {
+		if given[value.name] {
+			continue
+		}
+		if err = c.flagSet.Set(value.name, value.value); err != nil {
+			return fmt.Errorf("%s: %s: %w", p, value.key, err)
+		}
+		c.sources[value.name] = p + ": " + value.key
+	}
+	c.givenLabels = make(map[string]bool, len(*c.Labels))
+	for key := range *c.Labels {
+		c.givenLabels[key] = true
+	}
+	for key, value := range file.Labels {
+		if c.givenLabels[key] {
+			continue // a -label or SIRISH_LABELS entry of the same key wins
+		}
+		if err = c.Labels.Set(key + "=" + value); err != nil {
+			return fmt.Errorf("%s: labels: %w", p, err)
+		}
+	}
+	c.Interfaces = file.Interfaces
+	c.ConfigFile = p
+	return nil
+}
+
+// Validate checks the values no flag parser can, naming the flag, variable or config key they come from
+func (c *Config) Validate() error {
+	if *c.Backend != "apm" {
+		if _, err := wrapper.LookPlugin(*c.Backend); err != nil {
+			return fmt.Errorf("%s: backend %q is not supported, it is neither apm nor a plugin: %w", c.source("backend"), *c.Backend, err)
+		}
+		if *c.Template != "" {
+			return fmt.Errorf("%s: templates only apply to the apm backend, not to %s", c.source("template"), *c.Backend)
+		}
+	}
+	if *c.Suffix == "" {
+		return fmt.Errorf("%s: suffix can not be empty", c.source("suffix"))
+	}
+	if *c.Jobs < 0 {
+		return fmt.Errorf("%s: jobs can not be negative", c.source("j"))
+	}
+	return nil
+}
+
+// Effective is the merged configuration, as a config file with every field set and the overrides in use
+func (c *Config) Effective() File {
+	labels := map[string]string(*c.Labels)
+	return File{
+		Backend:    c.Backend,
+		Suffix:     c.Suffix,
+		Banner:     c.ShowBanner,
+		Fmt:        c.FormatImports,
+		Tg:         c.TraceGenerator,
+		Stream:     c.StreamSpans,
+		Closers:    c.CloserSpans,
+		Callbacks:  c.CallbackSpans,
+		Template:   c.Template,
+		SpanType:   c.SpanType,
+		Labels:     labels,
+		Output:     Output{Dir: c.OutDir, Pkg: c.OutPkg},
+		Interfaces: c.InterfaceOverrides(),
+	}
+}
+
+type flagValue struct {
+	key   string // key in the config file
+	name  string // name of the flag
+	value string
+}
+
+func (f *File) flagValues() []flagValue {
+	var values []flagValue
+	addString := func(key string, name string, value *string) {
+		if value != nil {
+			values = append(values, flagValue{key: key, name: name, value: *value})
+		}
+	}
+	addBool := func(key string, name string, value *bool) {
+		if value != nil {
+			values = append(values, flagValue{key: key, name: name, value: strconv.FormatBool(*value)})
+		}
+	}
+	addString("backend", "backend", f.Backend)
+	addString("suffix", "suffix", f.Suffix)
+	addBool("banner", "banner", f.Banner)
+	addBool("fmt", "fmt", f.Fmt)
+	addBool("tg", "tg", f.Tg)
+	addBool("stream", "stream", f.Stream)
+	addBool("closers", "closers", f.Closers)
+	addBool("callbacks", "callbacks", f.Callbacks)
+	addString("template", "template", f.Template)
+	addString("span_type", "span-type", f.SpanType)
+	addString("output.dir", "out-dir", f.Output.Dir)
+	addString("output.pkg", "out-pkg", f.Output.Pkg)
+	return values
+}
+
+// InterfaceOverrides are the interfaces of the config file without the options and label keys given on the
+// command line or by environment variables, which win over every value of the file
+func (c *Config) InterfaceOverrides() map[string]InterfaceConfig {
+	overrides := make(map[string]InterfaceConfig, len(c.Interfaces))
+	for name, override := range c.Interfaces {
+		if c.explicit("tg") {
+			override.Tg = nil
+		}
+		if c.explicit("stream") {
+			override.Stream = nil
+		}
+		if c.explicit("closers") {
+			override.Closers = nil
+		}
+		if c.explicit("callbacks") {
+			override.Callbacks = nil
+		}
+		if c.explicit("span-type") {
+			override.SpanType = nil
+		}
+		labels := make(map[string]string, len(override.Labels))
+		for key, value := range override.Labels {
+			if !c.givenLabels[key] {
+				labels[key] = value
+			}
+		}
+		override.Labels = labels
+		overrides[name] = override
+	}
+	return overrides
+}
+
+// MergeLabels returns the labels of the interface on top of global
+func (ic InterfaceConfig) MergeLabels(global dto.Labels) dto.Labels {
+	if len(ic.Labels) == 0 {
+		return global
+	}
+	merged := make(dto.Labels, len(global)+len(ic.Labels))
+	for key, value := range global {
+		merged[key] = value
+	}
+	for key, value := range ic.Labels {
+		merged[key] = value
+	}
+	return merged
+}
+
+// WriteEffective writes the merged configuration as yaml, naming the config file it was read from
+func (c *Config) WriteEffective(w io.Writer) error {
+	source := "no " + FileName + " found, flags and defaults only"
+	if c.ConfigFile != "" {
+		source = "merged from " + c.ConfigFile + " and flags"
+	}
+	if _, err := fmt.Fprintf(w, "# %s\n", source); err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Effective()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
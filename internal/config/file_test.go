@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeModule lays out a module with a config file at its root and returns the directory of pkg
+func writeModule(t *testing.T, config string) string {
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/m\n"), 0o644))
+	if config != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(root, FileName), []byte(config), 0o644))
+	}
+	dir := filepath.Join(root, "internal", "pkg")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	return dir
+}
+
+func TestFindFile(t *testing.T) {
+	dir := writeModule(t, "suffix: traced\n")
+	found, err := FindFile(dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(filepath.Dir(dir)), FileName), found)
+
+	// the nearest file wins
+	require.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte("suffix: near\n"), 0o644))
+	found, err = FindFile(dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, FileName), found)
+}
+
+func TestFindFile_StopsAtModuleRoot(t *testing.T) {
+	outer := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outer, FileName), []byte("suffix: outer\n"), 0o644))
+	root := filepath.Join(outer, "module")
+	require.NoError(t, os.MkdirAll(root, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/m\n"), 0o644))
+
+	found, err := FindFile(root)
+	require.NoError(t, err)
+	assert.Equal(t, "", found)
+}
+
+func TestLoad_FlagsTakePrecedence(t *testing.T) {
+	dir := writeModule(t, `
+suffix: traced
+tg: false
+span_type: db
+labels:
+  team: payments
+  tier: gold
+output:
+  pkg: tracing
+template: templates
+interfaces:
+  ProfileStore:
+    tg: true
+    span_type: cache
+    labels:
+      tier: silver
+`)
+	cfg := NewConfig("sirish", "dev")
+	require.NoError(t, cfg.Parse([]string{"-suffix", "wrapped", "-label", "tier=bronze"}))
+	require.NoError(t, cfg.Load(dir))
+
+	assert.Equal(t, "wrapped", *cfg.Suffix)
+	assert.Equal(t, false, *cfg.TraceGenerator)
+	assert.Equal(t, "db", *cfg.SpanType)
+	assert.Equal(t, "tracing", *cfg.OutPkg)
+	assert.Equal(t, dto.Labels{"team": "payments", "tier": "bronze"}, *cfg.Labels)
+	assert.Equal(t, filepath.Join(filepath.Dir(filepath.Dir(dir)), "templates"), *cfg.Template)
+	assert.Equal(t, filepath.Join(filepath.Dir(filepath.Dir(dir)), FileName), cfg.ConfigFile)
+
+	profileStore := cfg.Interfaces["ProfileStore"]
+	require.NotNil(t, profileStore.Tg)
+	assert.Equal(t, true, *profileStore.Tg)
+	assert.Equal(t, "cache", *profileStore.SpanType)
+	assert.Equal(t, dto.Labels{"team": "payments", "tier": "silver"}, profileStore.MergeLabels(*cfg.Labels))
+}
+
+func TestInterfaceOverrides_FlagsTakePrecedence(t *testing.T) {
+	dir := writeModule(t, `
+interfaces:
+  Store:
+    tg: true
+    stream: true
+    closers: true
+    span_type: cache
+    labels:
+      tier: silver
+      team: payments
+`)
+	t.Setenv("SIRISH_STREAM", "false")
+	cfg := NewConfig("sirish", "dev")
+	require.NoError(t, cfg.Parse([]string{"-tg=false", "-label", "tier=bronze"}))
+	require.NoError(t, cfg.Load(dir))
+
+	store := cfg.InterfaceOverrides()["Store"]
+	assert.Nil(t, store.Tg, "-tg is given")
+	assert.Nil(t, store.Stream, "SIRISH_STREAM is given")
+	require.NotNil(t, store.Closers)
+	assert.Equal(t, true, *store.Closers)
+	require.NotNil(t, store.SpanType)
+	assert.Equal(t, "cache", *store.SpanType)
+	assert.Equal(t, dto.Labels{"team": "payments", "tier": "bronze"}, store.MergeLabels(*cfg.Labels))
+
+	// the file keeps its values, only the overrides drop them
+	require.NotNil(t, cfg.Interfaces["Store"].Tg)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		err    string
+	}{
+		{name: "unknown key", config: "sufix: traced\n", err: "field sufix not found"},
+		{name: "unsupported backend", config: "backend: otel\n", err: `backend "otel" is not supported`},
+		{name: "flag backend", args: []string{"-backend", "otel"}, err: `backend "otel" is not supported`},
+		{name: "bad bool", config: "tg: sometimes\n", err: "cannot unmarshal"},
+		{name: "negative jobs", args: []string{"-j", "-1"}, err: "-j: jobs can not be negative"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeModule(t, test.config)
+			cfg := NewConfig("sirish", "dev")
+			require.NoError(t, cfg.Parse(test.args))
+			err := cfg.Load(dir)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestWriteEffective(t *testing.T) {
+	dir := writeModule(t, "span_type: db\n")
+	cfg := NewConfig("sirish", "dev")
+	require.NoError(t, cfg.Parse([]string{"-tg=false"}))
+	require.NoError(t, cfg.Load(dir))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, cfg.WriteEffective(buf))
+	for _, want := range []string{
+		"# merged from " + cfg.ConfigFile,
+		"backend: apm\n",
+		"suffix: sirish\n",
+		"tg: false\n",
+		"span_type: db\n",
+	} {
+		assert.Contains(t, buf.String(), want)
+	}
+}
+
+func TestValidate_PluginBackend(t *testing.T) {
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "sirish-gen-otel"), []byte("#!/bin/sh\n"), 0o755))
+	t.Setenv("PATH", bin)
+
+	dir := writeModule(t, "backend: otel\n")
+	cfg := NewConfig("sirish", "dev")
+	require.NoError(t, cfg.Parse(nil))
+	require.NoError(t, cfg.Load(dir))
+	assert.Equal(t, "otel", *cfg.Backend)
+
+	cfg = NewConfig("sirish", "dev")
+	require.NoError(t, cfg.Parse([]string{"-template", "wrapper.gotmpl"}))
+	err := cfg.Load(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "-template: templates only apply to the apm backend, not to otel")
+}
@@ -1,47 +1,169 @@
 package dto
 
-// Method defines how wrapper method signature will be
+// Method defines how wrapper method signature will be. the joined lists and the names of locals are only
+// there for the templates, sirish inspect -json and plugins leave them out
 type Method struct {
-	Name               string
-	SpecialName        string
-	Params             []ParamInfo
-	Results            []ResultInfo
-	HasNamedResult     bool
-	HasCtx             bool
-	ResultNames        string
-	ResultTypesNames   string
-	ResultOverallNames string
-	ParamsNames        string
-	ParamsOverallNames string
-	HasError           bool
-	ErrorName          string
-	CtxName            string
-	SpanName           string
+	Name               string       `json:"name"`
+	SpecialName        string       `json:"special_name"`
+	Params             []ParamInfo  `json:"params"`
+	Results            []ResultInfo `json:"results"`
+	HasNamedResult     bool         `json:"has_named_result"`
+	HasCtx             bool         `json:"has_ctx"`
+	ResultNames        string       `json:"-"`
+	ResultTypesNames   string       `json:"-"`
+	ResultOverallNames string       `json:"-"`
+	ParamsNames        string       `json:"-"`
+	ParamsOverallNames string       `json:"-"`
+	HasError           bool         `json:"has_error"`
+	ErrorName          string       `json:"error_name"`
+	CtxName            string       `json:"ctx_name"`
+	SpanName           string       `json:"-"`        // variable of the method span
+	Stream             *StreamInfo  `json:"stream"`   // first streaming result, nil if the method returns none
+	Closer             *CloserInfo  `json:"closer"`   // first closable result, nil if the method returns none
+	Locals             LocalNames   `json:"-"`        // identifiers the wrapper declares inside the method
+	Position           Position     `json:"position"` // where the method is declared
+}
+
+// HasCallback reports whether a param of m is a callback the wrapper traces
+func (m Method) HasCallback() bool {
+	for _, param := range m.Params {
+		if param.Callback != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Position is where a declaration starts in its source file, lines and columns start at 1
+type Position struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+// PkgNames are the names generated code uses for the packages sirish itself refers to
+type PkgNames struct {
+	Apm     string `json:"apm"`
+	Context string `json:"context"`
+	Sync    string `json:"sync"`
+	Atomic  string `json:"atomic"`
+}
+
+// LocalNames are the identifiers a wrapper method declares or refers to besides its params and results,
+// renamed whenever they would collide with them or with an import. package names are repeated so
+// nested templates only need the method
+type LocalNames struct {
+	PkgNames
+	Receiver   string `json:"receiver"`
+	Tx         string `json:"tx"`
+	StreamOnce string `json:"stream_once"`
+	StreamEnd  string `json:"stream_end"`
+	StreamIn   string `json:"stream_in"`
+	StreamOut  string `json:"stream_out"`
+	Items      string `json:"items"`
+	Item       string `json:"item"`
+	Key        string `json:"key"`
+	Ok         string `json:"ok"`
+	Exhausted  string `json:"exhausted"`
+	Yield      string `json:"yield"`
+	Wrapper    string `json:"wrapper"`  // wrapper of a result which is another sirish target
+	Returned   string `json:"returned"` // set once the method returned, callbacks called later are traced on their own
+	Links      string `json:"links"`    // links of the late callbacks to the method span
 }
 
 type TypeParamInfo struct {
-	Name       string // for example T or K
-	Constraint string // for example any, comparable or {interface | int64}
+	Name       string `json:"name"`       // for example T or K
+	Constraint string `json:"constraint"` // for example any, comparable or {interface | int64}
 }
 
 type ParamInfo struct {
-	Name string // can be empty
-	Type string // printable type, e.g. "context.Context"
+	Name     string        `json:"name"`     // can be empty
+	Type     string        `json:"type"`     // printable type, e.g. "context.Context"
+	Callback *CallbackInfo `json:"callback"` // set when the param is a function receiving a context first
+}
+
+// CallbackInfo describes a function parameter which the wrapper traces as a child span of the method.
+// like the ones of Method, its variables and joined lists are left out of json
+type CallbackInfo struct {
+	SpanName           string       `json:"span_name"` // e.g. "Store.WithTx.fn"
+	Inner              string       `json:"-"`         // variable keeping the callback given by the caller
+	Span               string       `json:"-"`         // variable of the callback span
+	Late               string       `json:"-"`         // variable of the transaction of a call made after the method returned
+	CtxName            string       `json:"ctx_name"`  // name of the context argument
+	Params             []ParamInfo  `json:"params"`
+	Results            []ResultInfo `json:"results"`
+	Variadic           bool         `json:"variadic"`
+	ErrorName          string       `json:"error_name"` // last error result, empty if the callback returns none
+	ParamsNames        string       `json:"-"`
+	ParamsOverallNames string       `json:"-"`
+	ResultNames        string       `json:"-"`
+	ResultTypesNames   string       `json:"-"`
 }
 
 type ResultInfo struct {
-	Name string // can be empty
-	Type string // printable type, e.g. "context.Context"
+	Name string `json:"name"` // can be empty
+	Type string `json:"type"` // printable type, e.g. "context.Context"
+	Zero string `json:"zero"` // expression of its zero value, e.g. nil, 0, "", T{} or *new(T)
+}
+
+// StreamKind tells how the caller consumes a streaming result
+type StreamKind string
+
+const (
+	StreamChan   StreamKind = "chan"   // <-chan T
+	StreamSeq    StreamKind = "seq"    // iter.Seq[T]
+	StreamSeq2   StreamKind = "seq2"   // iter.Seq2[K, V]
+	StreamCursor StreamKind = "cursor" // func() (T, bool)
+)
+
+// StreamInfo describes a result whose real work happens after the method returns
+type StreamInfo struct {
+	Kind     StreamKind `json:"kind"`
+	Result   string     `json:"result"`    // name of the result holding the stream
+	KeyType  string     `json:"key_type"`  // K of iter.Seq2, empty otherwise
+	ElemType string     `json:"elem_type"` // T of the stream, V of iter.Seq2
 }
 
+// CloserKind tells which closable resource a method returns
+type CloserKind string
+
+const (
+	CloserPlain      CloserKind = "Closer"          // io.Closer
+	CloserReader     CloserKind = "ReadCloser"      // io.ReadCloser
+	CloserWriter     CloserKind = "WriteCloser"     // io.WriteCloser
+	CloserReadWriter CloserKind = "ReadWriteCloser" // io.ReadWriteCloser
+	CloserTarget     CloserKind = "Target"          // another interface wrapped by sirish
+)
+
+// CloserInfo describes a result whose lifetime ends when it is closed
+type CloserInfo struct {
+	Kind   CloserKind `json:"kind"`
+	Result string     `json:"result"` // name of the result holding the resource
+	Type   string     `json:"type"`   // printable type, e.g. "io.ReadCloser" or the target interface name
+	Closes bool       `json:"closes"` // whether closing the resource ends the span, false for targets without Close() error
+}
+
+// InterfaceInfo is an interface to wrap and where its wrapper goes. the json names of it and the types it
+// holds are printed by sirish inspect -json, so fields keep them when they are renamed
 type InterfaceInfo struct {
-	Name       string
-	TypeParams []TypeParamInfo
-	Methods    []Method
-	FileName   string
-	FilePath   string
-	Package    string
-	Directory  string
+	Name       string          `json:"name"`
+	TypeParams []TypeParamInfo `json:"type_params"`
+	Methods    []Method        `json:"methods"`
+	Closable   bool            `json:"closable"` // has a Close() error method
+	Pkgs       PkgNames        `json:"-"`        // names of the packages wrapper bodies refer to
+	Imports    PkgImports      `json:"imports"`  // packages the method signatures refer to, with the names they use
+	FileName   string          `json:"file_name"`
+	FilePath   string          `json:"file_path"`
+	Package    string          `json:"package"`
+	Directory  string          `json:"directory"`
+
+	QualifiedName string   `json:"qualified_name"` // Name as the output package refers to it
+	OutPackage    string   `json:"out_package"`    // package the wrapper is generated in
+	OutDirectory  string   `json:"out_directory"`  // directory the wrapper is generated in
+	Declaration   string   `json:"declaration"`    // interface type sirish generates for Struct, empty for declared interfaces
+	Struct        string   `json:"struct"`         // struct implementing the generated interface
+	Func          bool     `json:"func"`           // a function type with a single method named after it, wrapped by Trace<Name>
+	Position      Position `json:"position"`       // where the interface, function type or struct is declared
 }
 
 // PkgImports is a map of imports which their key is path and value is possible alias
@@ -1,6 +1,10 @@
 package dto
 
-import "strings"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 type Types []string
 
@@ -26,3 +30,40 @@ func (t *Types) Exists(name string) bool {
 	}
 	return false
 }
+
+// StructTarget is a struct whose exported method set gets a generated interface and its wrapper
+type StructTarget struct {
+	Name      string
+	Interface string // name of the generated interface, Name + "Interface" by default
+}
+
+// Labels are span labels given as repeated key=value flags
+type Labels map[string]string
+
+func (l *Labels) String() string {
+	if l == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(*l))
+	for key := range *l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+(*l)[key])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (l *Labels) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("label %q is not in key=value form", s)
+	}
+	if *l == nil {
+		*l = make(Labels)
+	}
+	(*l)[strings.TrimSpace(key)] = value
+	return nil
+}
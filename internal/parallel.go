@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// Jobs is the number of workers to use for n, GOMAXPROCS when n is not positive
+func Jobs(n int) int {
+	if n <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return n
+}
+
+// ParallelMap calls fn for every item on at most jobs goroutines. the results keep the order of items,
+// and the errors of every failing item are joined in that order, so the output does not depend on scheduling
+func ParallelMap[T any, R any](jobs int, items []T, fn func(item T) (R, error)) ([]R, error) {
+	results := make([]R, len(items))
+	errs := make([]error, len(items))
+	jobs = min(Jobs(jobs), len(items))
+	if jobs <= 1 {
+		for i, item := range items {
+			results[i], errs[i] = fn(item)
+		}
+		return results, errors.Join(errs...)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], errs[i] = fn(items[i])
+			}
+		}()
+	}
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results, errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelMap_KeepsOrder(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	var running, peak atomic.Int32
+	results, err := ParallelMap(4, items, func(item int) (string, error) {
+		if now := running.Add(1); now > peak.Load() {
+			peak.Store(now)
+		}
+		defer running.Add(-1)
+		time.Sleep(time.Duration(100-item) * time.Microsecond) // later items finish first
+		return fmt.Sprint(item), nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 100)
+	for i, result := range results {
+		assert.Equal(t, fmt.Sprint(i), result)
+	}
+	assert.LessOrEqual(t, peak.Load(), int32(4))
+}
+
+func TestParallelMap_JoinsErrors(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+	_, err := ParallelMap(8, []int{0, 1, 2, 3}, func(item int) (int, error) {
+		switch item {
+		case 1:
+			return 0, first
+		case 3:
+			return 0, second
+		}
+		return item, nil
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, first)
+	assert.ErrorIs(t, err, second)
+	assert.Equal(t, "first\nsecond", err.Error(), "errors keep the order of the items")
+}
+
+func TestJobs(t *testing.T) {
+	assert.Equal(t, 3, Jobs(3))
+	assert.Positive(t, Jobs(0))
+	assert.Equal(t, Jobs(0), Jobs(-1))
+}
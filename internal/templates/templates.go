@@ -0,0 +1,8 @@
+package templates
+
+import "embed"
+
+// FS holds the templates shipped inside the sirish binary
+//
+//go:embed *.gotmpl
+var FS embed.FS
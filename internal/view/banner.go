@@ -41,7 +41,7 @@ func NewBanner(templatesMemoryEmbed embed.FS,
 	buildDate string,
 	builtBy string) View {
 	if pattern == "" {
-		pattern = "internal/templates/banner.gotmpl"
+		pattern = "banner.gotmpl"
 	}
 	return &banner{
 		templatesMemoryEmbed: templatesMemoryEmbed,
@@ -10,6 +10,7 @@ import (
 
 type Comment struct {
 	targetInterfaces dto.Types
+	targetStructs    []dto.StructTarget
 	fileAbsPath      string // absolutePath
 }
 
@@ -19,13 +20,20 @@ func NewCommentVisitor(filePath string) *Comment {
 	}
 }
 
-func (c *Comment) Traverse() {
+func (c *Comment) Traverse() error {
 	fSet := token.NewFileSet()
 	file, err := parser.ParseFile(fSet, c.fileAbsPath, nil, parser.ParseComments)
 	// you can use file name and file contents too as src.
 	if err != nil {
-		panic(err)
+		return err
 	}
+	c.Walk(file)
+	return nil
+}
+
+// Walk collects the directives of the already parsed file, which it does not change. it runs before
+// the TypeVisitor traverses the same AST
+func (c *Comment) Walk(file *ast.File) {
 	ast.Walk(c, file)
 }
 
@@ -33,17 +41,32 @@ func (c *Comment) GetTargets() dto.Types {
 	return c.targetInterfaces
 }
 
+// GetStructs returns the structs marked with sirish:struct Name [InterfaceName]
+func (c *Comment) GetStructs() []dto.StructTarget {
+	return c.targetStructs
+}
+
 func (c *Comment) Visit(node ast.Node) ast.Visitor {
 	if node == nil {
 		return nil // reached the end in depth traverse
 	}
 	switch nodeWithType := node.(type) {
 	case *ast.Comment:
-		if strings.Contains(nodeWithType.Text, "// sirish:") {
-			c.targetInterfaces = append(c.targetInterfaces, strings.TrimSpace(strings.Split(nodeWithType.Text, "// sirish:")[1]))
-		} else if strings.Contains(nodeWithType.Text, "//sirish:") {
-			c.targetInterfaces = append(c.targetInterfaces, strings.TrimSpace(strings.Split(nodeWithType.Text, "//sirish:")[1]))
+		// a directive starts the comment, prose mentioning //sirish: further in is no directive
+		target, ok := strings.CutPrefix(strings.TrimLeft(strings.TrimPrefix(nodeWithType.Text, "//"), " \t"), "sirish:")
+		if !ok {
+			return c
+		}
+		target = strings.TrimSpace(target)
+		if fields := strings.Fields(target); len(fields) > 1 && fields[0] == "struct" {
+			structTarget := dto.StructTarget{Name: fields[1], Interface: fields[1] + "Interface"}
+			if len(fields) > 2 {
+				structTarget.Interface = fields[2]
+			}
+			c.targetStructs = append(c.targetStructs, structTarget)
+			return c
 		}
+		c.targetInterfaces = append(c.targetInterfaces, target)
 	}
 	return c
 }
@@ -0,0 +1,228 @@
+package visitors
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/internal/dto"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// MethodSet is the method set of an interface read with go/types, which also has the methods the
+// interface embeds from others
+type MethodSet struct {
+	Interface *types.Interface
+	Pkg       *types.Package // package declaring the interface
+	FileSet   *token.FileSet // positions of the methods
+}
+
+// SetMethodSets gives the method sets of targets by name. the methods their interfaces embed are
+// wrapped as if the interfaces declared them. without them, the ones of the parsed file are type checked
+// by the loader when a target embeds another interface
+func (tv *TypeVisitor) SetMethodSets(methodSets map[string]MethodSet) {
+	tv.methodSets = methodSets
+}
+
+// localMethodSets type checks the package of file for the method sets of its targets which embed other
+// interfaces, nil when none does
+func (tv *TypeVisitor) localMethodSets(file *ast.File) (map[string]MethodSet, error) {
+	var embedding []string
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			interfaceType, ok := typeSpec.Type.(*ast.InterfaceType)
+			if !ok || interfaceType.Methods == nil || !tv.targetInterfaces.Exists(typeSpec.Name.Name) {
+				continue
+			}
+			for _, field := range interfaceType.Methods.List {
+				if len(field.Names) == 0 {
+					embedding = append(embedding, typeSpec.Name.Name)
+					break
+				}
+			}
+		}
+	}
+	if len(embedding) == 0 {
+		return nil, nil
+	}
+	pkg, err := tv.loader.Types(tv.fileAbsPath)
+	if err != nil {
+		return nil, err
+	}
+	methodSets := make(map[string]MethodSet, len(embedding))
+	for _, name := range embedding {
+		typeName, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("package %s has no declaration of %s", pkg.Path(), name)
+		}
+		iface, ok := typeName.Type().Underlying().(*types.Interface)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an interface", name)
+		}
+		methodSets[name] = MethodSet{Interface: iface, Pkg: pkg, FileSet: tv.loader.FileSet()}
+	}
+	return methodSets, nil
+}
+
+// handleEmbedded replaces the embedded fields of the targets with a method set by the methods they embed,
+// importing the packages the signatures of those methods refer to into file
+func (tv *TypeVisitor) handleEmbedded(file *ast.File) error {
+	if tv.methodSets == nil {
+		methodSets, err := tv.localMethodSets(file)
+		if err != nil {
+			return err
+		}
+		tv.methodSets = methodSets
+	}
+	if len(tv.methodSets) == 0 {
+		return nil
+	}
+	qualify, err := tv.importer(file)
+	if err != nil {
+		return err
+	}
+
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			methodSet, ok := tv.methodSets[typeSpec.Name.Name]
+			interfaceType, isInterface := typeSpec.Type.(*ast.InterfaceType)
+			if !ok || !isInterface || interfaceType.Methods == nil {
+				continue
+			}
+			var fields []*ast.Field
+			declared := make(scope)
+			for _, field := range interfaceType.Methods.List {
+				if len(field.Names) > 0 {
+					fields = append(fields, field)
+					declared.add(field.Names[0].Name)
+				}
+			}
+			if len(fields) == len(interfaceType.Methods.List) {
+				continue // embeds nothing
+			}
+			for i := 0; i < methodSet.Interface.NumMethods(); i++ {
+				method := methodSet.Interface.Method(i)
+				if declared.has(method.Name()) {
+					continue
+				}
+				field, err := tv.methodField(method, qualify(methodSet.Pkg), methodSet.FileSet)
+				if err != nil {
+					return fmt.Errorf("method %s embedded by %s: %w", method.Name(), typeSpec.Name.Name, err)
+				}
+				fields = append(fields, field)
+			}
+			interfaceType.Methods.List = fields
+		}
+	}
+	return nil
+}
+
+// importer qualifies the types of go/types signatures by the names file refers to their packages with,
+// importing the packages file does not into it. the types of from, the package declaring a signature, are
+// not qualified
+func (tv *TypeVisitor) importer(file *ast.File) (func(from *types.Package) types.Qualifier, error) {
+	declared, _, err := tv.packageDecls()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string) // path to its name in file
+	taken := declared.clone()
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Name != nil {
+			names[importPath] = spec.Name.Name
+			taken.add(spec.Name.Name)
+		}
+	}
+	return func(from *types.Package) types.Qualifier {
+		return func(pkg *types.Package) string {
+			if pkg.Path() == from.Path() {
+				return "" // the declaring package, qualified later when the wrappers go into another one
+			}
+			if name, ok := names[pkg.Path()]; ok {
+				return name
+			}
+			for _, spec := range file.Imports {
+				if spec.Name == nil && spec.Path.Value == strconv.Quote(pkg.Path()) {
+					names[pkg.Path()] = pkg.Name() // the name the file uses, whatever the path guesses
+					return pkg.Name()
+				}
+			}
+			name := taken.fresh(pkg.Name())
+			spec := &ast.ImportSpec{Name: ast.NewIdent(name), Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(pkg.Path())}}
+			file.Imports = append(file.Imports, spec)
+			file.Decls = append([]ast.Decl{&ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{spec}}}, file.Decls...)
+			names[pkg.Path()] = name
+			return name
+		}
+	}, nil
+}
+
+// methodField writes method as a field of an interface declared in the parsed file, positioned where
+// method is declared
+func (tv *TypeVisitor) methodField(method *types.Func, qualifier types.Qualifier, fSet *token.FileSet) (*ast.Field, error) {
+	funcType, err := tv.funcType(method.Type().(*types.Signature), qualifier)
+	if err != nil {
+		return nil, err
+	}
+	field := &ast.Field{Names: []*ast.Ident{ast.NewIdent(method.Name())}, Type: funcType}
+	if tv.embedded == nil {
+		tv.embedded = make(map[*ast.Field]dto.Position)
+	}
+	position := fSet.Position(method.Pos())
+	tv.embedded[field] = dto.Position{File: position.Filename, Line: position.Line, Column: position.Column}
+	return field, nil
+}
+
+// funcType writes signature as the AST of a method declared in the parsed file
+func (tv *TypeVisitor) funcType(signature *types.Signature, qualifier types.Qualifier) (*ast.FuncType, error) {
+	params, err := tv.fieldList(signature.Params(), signature.Variadic(), qualifier)
+	if err != nil {
+		return nil, err
+	}
+	results, err := tv.fieldList(signature.Results(), false, qualifier)
+	if err != nil {
+		return nil, err
+	}
+	return &ast.FuncType{Params: params, Results: results}, nil
+}
+
+func (tv *TypeVisitor) fieldList(tuple *types.Tuple, variadic bool, qualifier types.Qualifier) (*ast.FieldList, error) {
+	list := new(ast.FieldList)
+	for i := 0; i < tuple.Len(); i++ {
+		v := tuple.At(i)
+		typ := v.Type()
+		last := variadic && i == tuple.Len()-1
+		if last {
+			typ = typ.(*types.Slice).Elem()
+		}
+		// parsed into the FileSet of the run, so printing the type finds its positions
+		expr, err := parser.ParseExprFrom(tv.fSet, "", types.TypeString(typ, qualifier), 0)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			expr = &ast.Ellipsis{Elt: expr}
+		}
+		field := &ast.Field{Type: expr}
+		if v.Name() != "" {
+			field.Names = []*ast.Ident{ast.NewIdent(v.Name())}
+		}
+		list.List = append(list.List, field)
+	}
+	return list, nil
+}
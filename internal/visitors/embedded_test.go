@@ -0,0 +1,38 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalEmbeddedWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("embedded.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Repo"})
+	require.NoError(t, typeVisitor.Traverse())
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+
+	methods := make(map[string]dto.Method)
+	for _, method := range interfaces[0].Methods {
+		methods[method.Name] = method
+	}
+	require.Len(t, methods, 3)
+	assert.Equal(t, "error", methods["Close"].Results[0].Type)
+	assert.Equal(t, "io.go", filepath.Base(methods["Close"].Position.File))
+	assert.Equal(t, "*Account", methods["Lookup"].Results[0].Type)
+	assert.Equal(t, abs, methods["Lookup"].Position.File)
+	assert.True(t, interfaces[0].Closable)
+
+	// the types of the package are qualified when the wrapper goes into another one
+	typeVisitor = NewTypeVisitor(abs, dto.Types{"Repo"})
+	typeVisitor.SetOutput(t.TempDir(), "tracing")
+	require.NoError(t, typeVisitor.Traverse())
+	qualified := typeVisitor.GetWrappedInterfaces()[0].Methods
+	require.Len(t, qualified, 3)
+	assert.Equal(t, "Lookup", qualified[2].Name)
+	assert.Equal(t, "*test_samples.Account", qualified[2].Results[0].Type)
+}
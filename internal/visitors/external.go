@@ -0,0 +1,218 @@
+package visitors
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// ExternalTarget is an interface declared in another package, written as import/path.Name
+type ExternalTarget struct {
+	Path string
+	Name string
+}
+
+func (et ExternalTarget) String() string {
+	return et.Path + "." + et.Name
+}
+
+// SplitTargets separates the interfaces of the parsed file from the ones of other packages
+func SplitTargets(targets dto.Types) (dto.Types, []ExternalTarget) {
+	var local dto.Types
+	var external []ExternalTarget
+	for _, target := range targets {
+		i := strings.LastIndex(target, ".")
+		if i <= 0 || i == len(target)-1 {
+			local = append(local, target)
+			continue
+		}
+		external = append(external, ExternalTarget{Path: target[:i], Name: target[i+1:]})
+	}
+	return local, external
+}
+
+// TraverseExternal extracts the targets of other packages, resolved with the module of dir, parsing the
+// declaring files with loader on jobs workers. the packages are read from the module cache only, so they
+// have to be required by that module
+func TraverseExternal(loader *Loader, dir string, targets []ExternalTarget, outDir string, outPkg string, jobs int) ([]dto.InterfaceInfo, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	if loader == nil {
+		loader = NewLoader()
+	}
+	if outDir == "" && outPkg == "" {
+		return nil, fmt.Errorf("%s is declared in another package, set -out-pkg or -out-dir to generate its wrapper", targets[0])
+	}
+	if outDir == "" {
+		outDir = outPkg
+	}
+	if !filepath.IsAbs(outDir) {
+		outDir = filepath.Join(dir, outDir) // the declaring files live in the module cache
+	}
+
+	var paths []string
+	for _, target := range targets {
+		paths = append(paths, target.Path)
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles,
+		Dir:  dir,
+		Env:  append(os.Environ(), "GOPROXY=off"),
+	}, paths...)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]string, len(pkgs)) // import path to go files
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("loading %s: %v", pkg.PkgPath, pkg.Errors[0])
+		}
+		files[pkg.PkgPath] = pkg.GoFiles
+	}
+
+	var order []string
+	byFile := make(map[string]dto.Types) // declaring file to its targets
+	importPaths := make(map[string]string)
+	var embedding []ExternalTarget // targets embedding other interfaces, declared by embeddingFiles
+	var embeddingFiles []string
+	for _, target := range targets {
+		goFiles, ok := files[target.Path]
+		if !ok {
+			return nil, fmt.Errorf("package %s of %s is not found", target.Path, target)
+		}
+		file, embeds, err := declaringFile(loader, goFiles, target)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := byFile[file]; !ok {
+			order = append(order, file)
+		}
+		byFile[file] = append(byFile[file], target.Name)
+		importPaths[file] = target.Path
+		if embeds {
+			embedding = append(embedding, target)
+			embeddingFiles = append(embeddingFiles, file)
+		}
+	}
+	loadedSets, err := loadMethodSets(dir, embedding)
+	if err != nil {
+		return nil, err
+	}
+	methodSets := make(map[string]map[string]MethodSet) // declaring file to the method sets of its targets
+	for i, target := range embedding {
+		if methodSets[embeddingFiles[i]] == nil {
+			methodSets[embeddingFiles[i]] = make(map[string]MethodSet)
+		}
+		methodSets[embeddingFiles[i]][target.Name] = loadedSets[i]
+	}
+
+	perFile, err := internal.ParallelMap(jobs, order, func(file string) ([]dto.InterfaceInfo, error) {
+		typeVisitor := NewExternalTypeVisitor(file, importPaths[file], byFile[file])
+		typeVisitor.SetLoader(loader)
+		typeVisitor.SetOutput(outDir, outPkg)
+		typeVisitor.SetMethodSets(methodSets[file])
+		if err := typeVisitor.Traverse(); err != nil {
+			return nil, err
+		}
+		return typeVisitor.GetWrappedInterfaces(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var interfaces []dto.InterfaceInfo
+	for _, each := range perFile {
+		interfaces = append(interfaces, each...)
+	}
+	return interfaces, nil
+}
+
+// loadMethodSets type checks the packages of targets, which embed other interfaces, to read their method
+// sets in the same order. it is only done for those since it loads their dependencies from source
+func loadMethodSets(dir string, targets []ExternalTarget) ([]MethodSet, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	var paths []string
+	for _, target := range targets {
+		paths = append(paths, target.Path)
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes,
+		Dir:  dir,
+		Env:  append(os.Environ(), "GOPROXY=off"),
+	}, paths...)
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[string]*packages.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("loading %s: %v", pkg.PkgPath, pkg.Errors[0])
+		}
+		loaded[pkg.PkgPath] = pkg
+	}
+	methodSets := make([]MethodSet, 0, len(targets))
+	for _, target := range targets {
+		pkg := loaded[target.Path]
+		if pkg == nil || pkg.Types == nil {
+			return nil, fmt.Errorf("package %s of %s is not type checked", target.Path, target)
+		}
+		typeName, ok := pkg.Types.Scope().Lookup(target.Name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("package %s has no declaration of %s", target.Path, target.Name)
+		}
+		iface, ok := typeName.Type().Underlying().(*types.Interface)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an interface", target)
+		}
+		methodSets = append(methodSets, MethodSet{Interface: iface, Pkg: pkg.Types, FileSet: pkg.Fset})
+	}
+	return methodSets, nil
+}
+
+// declaringFile finds the file of goFiles declaring the target interface, and whether the interface
+// embeds others
+func declaringFile(loader *Loader, goFiles []string, target ExternalTarget) (string, bool, error) {
+	for _, goFile := range goFiles {
+		file, err := loader.Cached(goFile)
+		if err != nil {
+			return "", false, err
+		}
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if typeSpec.Name.Name != target.Name {
+					continue
+				}
+				var embeds bool
+				switch declared := typeSpec.Type.(type) {
+				case *ast.InterfaceType:
+					for _, field := range declared.Methods.List {
+						embeds = embeds || len(field.Names) == 0
+					}
+				case *ast.FuncType:
+				default:
+					return "", false, fmt.Errorf("%s is not an interface or a function type", target)
+				}
+				if typeSpec.Assign.IsValid() {
+					return "", false, fmt.Errorf("%s is an alias, refer to the type it aliases", target)
+				}
+				return goFile, embeds, nil
+			}
+		}
+	}
+	return "", false, fmt.Errorf("package %s has no declaration of %s", target.Path, target.Name)
+}
@@ -0,0 +1,94 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitTargetsWorks(t *testing.T) {
+	local, external := SplitTargets(dto.Types{"Store", "database/sql/driver.Conn", "github.com/redis/go-redis/v9.Cmdable", "gopkg.in/yaml.v3.Marshaler", "Bad."})
+	assert.Equal(t, dto.Types{"Store", "Bad."}, local)
+	assert.Equal(t, []ExternalTarget{
+		{Path: "database/sql/driver", Name: "Conn"},
+		{Path: "github.com/redis/go-redis/v9", Name: "Cmdable"},
+		{Path: "gopkg.in/yaml.v3", Name: "Marshaler"},
+	}, external)
+}
+
+func TestTraverseExternalWorks(t *testing.T) {
+	dir := t.TempDir()
+	interfaces, err := TraverseExternal(nil, ".", []ExternalTarget{
+		{Path: "database/sql/driver", Name: "Conn"},
+		{Path: "database/sql/driver", Name: "Tx"},
+		{Path: "github.com/labstack/echo/v4", Name: "Renderer"},
+	}, dir, "tracing", 0)
+	require.NoError(t, err)
+	require.Len(t, interfaces, 3)
+
+	conn := interfaces[0]
+	assert.Equal(t, "driver.Conn", conn.QualifiedName)
+	assert.Equal(t, "tracing", conn.OutPackage)
+	assert.Equal(t, dir, conn.OutDirectory)
+	assert.Equal(t, "Conn.driver.go", conn.FileName)
+	assert.Equal(t, "driver", conn.Imports["database/sql/driver"])
+	require.Len(t, conn.Methods, 3)
+	assert.Equal(t, "driver.Stmt", conn.Methods[0].Results[0].Type)
+	assert.Equal(t, "driver.Tx", interfaces[1].QualifiedName)
+
+	renderer := interfaces[2]
+	assert.Equal(t, "echo.Renderer", renderer.QualifiedName)
+	assert.Equal(t, "echo", renderer.Imports["github.com/labstack/echo/v4"])
+	assert.Equal(t, "echo.Context", renderer.Methods[0].Params[3].Type)
+}
+
+func TestTraverseExternalEmbeddedWorks(t *testing.T) {
+	interfaces, err := TraverseExternal(nil, ".", []ExternalTarget{
+		{Path: "io", Name: "ReadCloser"},
+		{Path: "io/fs", Name: "ReadDirFile"},
+	}, t.TempDir(), "tracing", 0)
+	require.NoError(t, err)
+	require.Len(t, interfaces, 2)
+
+	readCloser := interfaces[0]
+	require.Len(t, readCloser.Methods, 2)
+	assert.Equal(t, "Close", readCloser.Methods[0].Name)
+	assert.Equal(t, "Read", readCloser.Methods[1].Name)
+	assert.Equal(t, "[]byte", readCloser.Methods[1].Params[0].Type)
+	assert.True(t, readCloser.Closable)
+	assert.Equal(t, "io.go", filepath.Base(readCloser.Methods[1].Position.File))
+	assert.Empty(t, readCloser.Imports["io"], "only the wrapped interface refers to io")
+
+	readDirFile := interfaces[1]
+	methods := make(map[string]dto.Method, len(readDirFile.Methods))
+	for _, method := range readDirFile.Methods {
+		methods[method.Name] = method
+	}
+	require.Len(t, methods, 4)
+	assert.Equal(t, "fs.FileInfo", methods["Stat"].Results[0].Type)
+	assert.Equal(t, "[]fs.DirEntry", methods["ReadDir"].Results[0].Type)
+	assert.Equal(t, "fs", readDirFile.Imports["io/fs"])
+}
+
+func TestTraverseExternalFails(t *testing.T) {
+	type scenario struct {
+		target ExternalTarget
+		outPkg string
+		want   string
+	}
+	scenarios := map[string]scenario{
+		"NoOutput":     {target: ExternalTarget{Path: "database/sql/driver", Name: "Conn"}, want: "set -out-pkg or -out-dir"},
+		"NotInterface": {target: ExternalTarget{Path: "database/sql/driver", Name: "Value"}, outPkg: "tracing", want: "database/sql/driver.Value is not an interface"},
+		"Missing":      {target: ExternalTarget{Path: "database/sql/driver", Name: "Missing"}, outPkg: "tracing", want: "has no declaration of Missing"},
+		"NotRequired":  {target: ExternalTarget{Path: "example.com/not/required", Name: "Client"}, outPkg: "tracing", want: "example.com/not/required"},
+	}
+	for name, s := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			_, err := TraverseExternal(nil, ".", []ExternalTarget{s.target}, "", s.outPkg, 0)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), s.want)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package visitors
+
+import (
+	"go/ast"
+)
+
+// handleFuncs turns every target function type of file into an interface with a single method
+// named after it, so its wrapper is built like the ones of interfaces
+func (tv *TypeVisitor) handleFuncs(file *ast.File) {
+	tv.funcs = make(scope)
+	ast.Inspect(file, func(node ast.Node) bool {
+		typeSpec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		funcType, ok := typeSpec.Type.(*ast.FuncType)
+		if !ok || typeSpec.Assign.IsValid() || !tv.targetInterfaces.Exists(typeSpec.Name.Name) {
+			return false
+		}
+		tv.funcs.add(typeSpec.Name.Name)
+		typeSpec.Type = &ast.InterfaceType{
+			Interface: funcType.Pos(),
+			Methods: &ast.FieldList{List: []*ast.Field{{
+				Names: []*ast.Ident{ast.NewIdent(typeSpec.Name.Name)},
+				Type:  funcType,
+			}}},
+		}
+		return false
+	})
+}
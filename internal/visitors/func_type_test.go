@@ -0,0 +1,37 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFuncTypesWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("func_types.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Handler", "Lookup", "Dispatcher"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 3)
+
+	handler := interfaces[0]
+	assert.Equal(t, "Handler", handler.Name)
+	assert.True(t, handler.Func)
+	require.Len(t, handler.Methods, 1)
+	assert.Equal(t, "Handler", handler.Methods[0].Name)
+	assert.Equal(t, "Handler", handler.Methods[0].SpecialName)
+	assert.True(t, handler.Methods[0].HasCtx)
+	assert.True(t, handler.Methods[0].HasError)
+
+	lookup := interfaces[1]
+	assert.True(t, lookup.Func)
+	assert.False(t, lookup.Methods[0].HasCtx)
+	assert.Equal(t, "value, found", lookup.Methods[0].ResultNames)
+
+	// a function type result is not wrapped like a closable interface
+	dispatcher := interfaces[2]
+	assert.False(t, dispatcher.Func)
+	assert.Nil(t, dispatcher.Methods[0].Closer)
+}
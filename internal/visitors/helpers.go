@@ -2,14 +2,12 @@ package visitors
 
 import (
 	"bytes"
-	"crypto/rand"
 	"fmt"
 	"github.com/pm1381/sirish/internal/dto"
 	"go/ast"
 	"go/printer"
 	"go/token"
-	"log"
-	"math/big"
+	"hash/fnv"
 )
 
 const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -40,10 +38,16 @@ func GenerateUniqueValues(primaryTargets dto.Types, secondaryTargets dto.Types)
 	return *res
 }
 
+// MethodParamSnowflake names an unnamed param or result with letters unlikely to be used by hand. the letters
+// are a hash of the other parts, so generating twice gives the same wrapper and check can compare them
 func MethodParamSnowflake(methodName string, paramIndex int, nameIndex int, length int, exactKey string) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s/%s/%d/%d", methodName, exactKey, paramIndex, nameIndex)
+	sum := hash.Sum64()
 	b := make([]byte, length)
 	for i := range b {
-		b[i] = letters[cryptoRandSecure(int64(len(letters)))]
+		b[i] = letters[sum%uint64(len(letters))]
+		sum /= uint64(len(letters))
 	}
 	return fmt.Sprintf("%s%s%s_%d_%d",
 		methodName,
@@ -53,11 +57,3 @@ func MethodParamSnowflake(methodName string, paramIndex int, nameIndex int, leng
 		nameIndex,
 	)
 }
-
-func cryptoRandSecure(max int64) int64 {
-	nBig, err := rand.Int(rand.Reader, big.NewInt(max))
-	if err != nil {
-		log.Println(err)
-	}
-	return nBig.Int64()
-}
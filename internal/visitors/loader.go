@@ -0,0 +1,162 @@
+package visitors
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Loader parses the go files of a run into one FileSet, so positions of every file resolve through it.
+// files read for their declarations only, like the other files of a package, are parsed once and shared
+// by every target of the run. it is safe for concurrent use
+type Loader struct {
+	fSet  *token.FileSet
+	mu    sync.Mutex
+	files map[string]*cachedFile
+	dirs  map[string]*cachedDir
+	pkgs  map[string]*cachedPkg
+}
+
+type cachedFile struct {
+	once sync.Once
+	file *ast.File
+	err  error
+}
+
+type cachedPkg struct {
+	once sync.Once
+	pkg  *types.Package
+	err  error
+}
+
+type cachedDir struct {
+	once  sync.Once
+	files []string
+	err   error
+}
+
+// NewLoader starts a loader with nothing cached, one is shared by the files of a run
+func NewLoader() *Loader {
+	return &Loader{
+		fSet:  token.NewFileSet(),
+		files: make(map[string]*cachedFile),
+		dirs:  make(map[string]*cachedDir),
+		pkgs:  make(map[string]*cachedPkg),
+	}
+}
+
+// FileSet is the FileSet of every file the loader parses
+func (l *Loader) FileSet() *token.FileSet {
+	return l.fSet
+}
+
+// Parse parses path for a visitor which changes the AST, like TypeVisitor does. it is never shared
+func (l *Loader) Parse(path string) (*ast.File, error) {
+	return parser.ParseFile(l.fSet, path, nil, parser.ParseComments)
+}
+
+// Cached returns the AST of path shared by every caller, parsing it on the first call. callers must not
+// change it
+func (l *Loader) Cached(path string) (*ast.File, error) {
+	l.mu.Lock()
+	cached, ok := l.files[path]
+	if !ok {
+		cached = new(cachedFile)
+		l.files[path] = cached
+	}
+	l.mu.Unlock()
+	cached.once.Do(func() {
+		cached.file, cached.err = parser.ParseFile(l.fSet, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+	})
+	return cached.file, cached.err
+}
+
+// PackageNames loads the names the packages of paths declare, resolved with the module of dir. like
+// TraverseExternal, it reads the module cache only
+func (l *Loader) PackageNames(dir string, paths ...string) (map[string]string, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName,
+		Dir:  dir,
+		Env:  append(os.Environ(), "GOPROXY=off"),
+	}, paths...)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(pkgs))
+	for _, pkg := range pkgs {
+		if len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("loading %s: %v", pkg.PkgPath, pkg.Errors[0])
+		}
+		names[pkg.PkgPath] = pkg.Name
+	}
+	return names, nil
+}
+
+// Types type checks the package of the go file path from source, with its dependencies, once per loader.
+// it is slow, so only done for targets whose methods are not all in their syntax. errors in the other files
+// of the package, like stale wrappers, are left to the compiler; only the ones of path fail
+func (l *Loader) Types(path string) (*types.Package, error) {
+	l.mu.Lock()
+	cached, ok := l.pkgs[path]
+	if !ok {
+		cached = new(cachedPkg)
+		l.pkgs[path] = cached
+	}
+	l.mu.Unlock()
+	cached.once.Do(func() {
+		var pkgs []*packages.Package
+		pkgs, cached.err = packages.Load(&packages.Config{
+			Mode: packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes,
+			Dir:  filepath.Dir(path),
+			Fset: l.fSet,
+			Env:  append(os.Environ(), "GOPROXY=off"),
+		}, "file="+path)
+		if cached.err != nil {
+			return
+		}
+		if len(pkgs) != 1 || pkgs[0].Types == nil {
+			cached.err = fmt.Errorf("package of %s is not type checked", path)
+			return
+		}
+		for _, pkgErr := range pkgs[0].Errors {
+			if pkgErr.Pos == "" || strings.HasPrefix(pkgErr.Pos, path+":") {
+				cached.err = fmt.Errorf("type checking %s: %v", path, pkgErr)
+				return
+			}
+		}
+		cached.pkg = pkgs[0].Types
+	})
+	return cached.pkg, cached.err
+}
+
+// GoFiles lists the go files of dir which are not tests, read once per loader
+func (l *Loader) GoFiles(dir string) ([]string, error) {
+	l.mu.Lock()
+	cached, ok := l.dirs[dir]
+	if !ok {
+		cached = new(cachedDir)
+		l.dirs[dir] = cached
+	}
+	l.mu.Unlock()
+	cached.once.Do(func() {
+		var entries []os.DirEntry
+		if entries, cached.err = os.ReadDir(dir); cached.err != nil {
+			return
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+				cached.files = append(cached.files, filepath.Join(dir, name))
+			}
+		}
+	})
+	return cached.files, cached.err
+}
@@ -0,0 +1,81 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoaderCaches(t *testing.T) {
+	abs := internal.GetTestPathHelper("struct.go", "visitors")
+	loader := NewLoader()
+
+	cached, err := loader.Cached(abs)
+	require.NoError(t, err)
+	again, err := loader.Cached(abs)
+	require.NoError(t, err)
+	assert.Same(t, cached, again)
+
+	parsed, err := loader.Parse(abs)
+	require.NoError(t, err)
+	assert.NotSame(t, cached, parsed, "files parsed to be changed are never shared")
+
+	goFiles, err := loader.GoFiles(filepath.Dir(abs))
+	require.NoError(t, err)
+	assert.Contains(t, goFiles, abs)
+}
+
+func TestLoaderSharedByVisitors(t *testing.T) {
+	abs := internal.GetTestPathHelper("struct.go", "visitors")
+	loader := NewLoader()
+	traverse := func() dto.InterfaceInfo {
+		file, err := loader.Parse(abs)
+		require.NoError(t, err)
+		commentVisitor := NewCommentVisitor(abs)
+		commentVisitor.Walk(file)
+		typeVisitor := NewTypeVisitor(abs, nil)
+		typeVisitor.SetLoader(loader)
+		typeVisitor.SetStructs(commentVisitor.GetStructs()[:1])
+		require.NoError(t, typeVisitor.TraverseFile(file))
+		require.Len(t, typeVisitor.GetWrappedInterfaces(), 1)
+		return typeVisitor.GetWrappedInterfaces()[0]
+	}
+
+	first := traverse()
+	assert.Equal(t, "UserServiceInterface", first.Name)
+	assert.Equal(t, "time.Time", first.Methods[2].Params[1].Type)
+	assert.Equal(t, first, traverse(), "the cached files of the package are not changed by a traverse")
+
+	// struct_methods.go still refers to time as stdtime, the renaming happened on a copy
+	methods, err := loader.Cached(filepath.Join(filepath.Dir(abs), "struct_methods.go"))
+	require.NoError(t, err)
+	var qualifiers []string
+	ast.Inspect(methods, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok {
+				qualifiers = append(qualifiers, ident.Name)
+			}
+		}
+		return true
+	})
+	assert.Contains(t, qualifiers, "stdtime")
+}
+
+func TestLoaderParsesTargetFileOnce(t *testing.T) {
+	abs := internal.GetTestPathHelper("zero.go", "visitors")
+	loader := NewLoader()
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Zeros"})
+	typeVisitor.SetLoader(loader)
+	require.NoError(t, typeVisitor.Traverse())
+	assert.Equal(t, "ZeroPoint{}", typeVisitor.GetWrappedInterfaces()[0].Methods[2].Results[0].Zero)
+
+	// its declarations are read from the AST Traverse parsed, the cache only holds the other files
+	_, typeSpecs, err := typeVisitor.packageDecls()
+	require.NoError(t, err)
+	assert.Contains(t, typeSpecs, "ZeroPoint")
+	assert.NotContains(t, loader.files, abs)
+}
@@ -0,0 +1,292 @@
+package visitors
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// SetOutput makes the wrappers be generated into dir as package pkg. dir is relative to the
+// parsed file when it is not absolute, pkg defaults to the base of dir and dir to pkg next to the file
+func (tv *TypeVisitor) SetOutput(dir string, pkg string) {
+	tv.outDir = dir
+	tv.outPkg = pkg
+}
+
+// OutputDirectory is where the wrappers of the file at fileAbsPath are written for SetOutput(dir, pkg)
+func OutputDirectory(fileAbsPath string, dir string, pkg string) string {
+	srcDir := filepath.Dir(fileAbsPath)
+	switch {
+	case dir == "" && pkg == "":
+		return srcDir
+	case dir == "":
+		return filepath.Join(srcDir, pkg)
+	case filepath.IsAbs(dir):
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(srcDir, dir)
+}
+
+// handleOutput resolves where the wrappers are written and, when it is another package,
+// qualifies the types of the source package the targets refer to
+func (tv *TypeVisitor) handleOutput(file *ast.File, specs []*ast.TypeSpec) error {
+	srcDir := filepath.Dir(tv.fileAbsPath)
+	tv.outDirectory, tv.outPackage = OutputDirectory(tv.fileAbsPath, tv.outDir, tv.outPkg), file.Name.Name
+	if tv.outDir == "" && tv.outPkg == "" {
+		return nil
+	}
+	tv.outPackage = tv.outPkg
+	if tv.outPackage == "" {
+		tv.outPackage = importName(filepath.Base(tv.outDirectory))
+	}
+	if tv.outDirectory == srcDir {
+		if tv.outPackage != file.Name.Name {
+			return fmt.Errorf("output package %s differs from package %s which is already in %s", tv.outPackage, file.Name.Name, srcDir)
+		}
+		return nil
+	}
+	if !token.IsIdentifier(tv.outPackage) {
+		return fmt.Errorf("output package %q is not a valid package name", tv.outPackage)
+	}
+
+	var err error
+	if tv.srcPath == "" {
+		if tv.srcPath, err = importPath(srcDir); err != nil {
+			return err
+		}
+	}
+	tv.srcAlias = tv.fileScope.fresh(file.Name.Name)
+	tv.qualifiers[tv.srcAlias] = tv.srcPath
+	var declared scope // without dot imports, every name besides the builtins is declared by the package
+	for _, spec := range file.Imports {
+		if spec.Name != nil && spec.Name.Name == "." {
+			if declared, _, err = tv.packageDecls(); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	for _, spec := range specs {
+		q := qualifier{tv: tv, iface: spec.Name.Name, declared: declared, typeParams: make(scope)}
+		if structName, ok := tv.structs[spec.Name.Name]; ok && !token.IsExported(structName) {
+			return fmt.Errorf("struct %s is unexported and cannot be referenced from package %s", structName, tv.outPackage)
+		}
+		if !token.IsExported(spec.Name.Name) {
+			return fmt.Errorf("%s: interface %s is unexported and cannot be wrapped from package %s", tv.fSet.Position(spec.Pos()), spec.Name.Name, tv.outPackage)
+		}
+		if spec.TypeParams != nil {
+			for _, field := range spec.TypeParams.List {
+				for _, name := range field.Names {
+					q.typeParams.add(name.Name)
+				}
+			}
+			if err = q.fields(spec.TypeParams); err != nil {
+				return err
+			}
+		}
+		for _, method := range spec.Type.(*ast.InterfaceType).Methods.List {
+			for _, name := range method.Names {
+				if !name.IsExported() {
+					return fmt.Errorf("%s: method %s of %s is unexported and cannot be implemented from package %s", tv.fSet.Position(name.Pos()), name.Name, spec.Name.Name, tv.outPackage)
+				}
+			}
+		}
+		if err = q.fields(spec.Type.(*ast.InterfaceType).Methods); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// qualifier rewrites the identifiers declared by the source package into selectors of it
+type qualifier struct {
+	tv         *TypeVisitor
+	iface      string
+	declared   scope // package level names of the source package, nil when the file has no dot imports
+	typeParams scope
+}
+
+func (q qualifier) fields(list *ast.FieldList) (err error) {
+	if list == nil {
+		return nil
+	}
+	for _, field := range list.List {
+		if field.Type, err = q.expr(field.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q qualifier) exprs(list []ast.Expr) (err error) {
+	for i := range list {
+		if list[i], err = q.expr(list[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q qualifier) expr(expr ast.Expr) (ast.Expr, error) {
+	var err error
+	switch node := expr.(type) {
+	case *ast.Ident:
+		if q.typeParams.has(node.Name) || types.Universe.Lookup(node.Name) != nil || q.declared != nil && !q.declared.has(node.Name) {
+			return node, nil // builtin, type param or dot imported
+		}
+		if !node.IsExported() {
+			return nil, fmt.Errorf("%s: %s refers to unexported %s which cannot be referenced from package %s",
+				q.tv.fSet.Position(node.Pos()), q.iface, node.Name, q.tv.outPackage)
+		}
+		return &ast.SelectorExpr{X: &ast.Ident{NamePos: node.Pos(), Name: q.tv.srcAlias}, Sel: node}, nil
+	case *ast.StarExpr:
+		node.X, err = q.expr(node.X)
+	case *ast.ParenExpr:
+		node.X, err = q.expr(node.X)
+	case *ast.UnaryExpr:
+		node.X, err = q.expr(node.X)
+	case *ast.BinaryExpr:
+		if node.X, err = q.expr(node.X); err == nil {
+			node.Y, err = q.expr(node.Y)
+		}
+	case *ast.Ellipsis:
+		if node.Elt != nil {
+			node.Elt, err = q.expr(node.Elt)
+		}
+	case *ast.ArrayType:
+		if node.Len != nil {
+			if node.Len, err = q.expr(node.Len); err != nil {
+				return nil, err
+			}
+		}
+		node.Elt, err = q.expr(node.Elt)
+	case *ast.MapType:
+		if node.Key, err = q.expr(node.Key); err == nil {
+			node.Value, err = q.expr(node.Value)
+		}
+	case *ast.ChanType:
+		node.Value, err = q.expr(node.Value)
+	case *ast.IndexExpr:
+		if node.X, err = q.expr(node.X); err == nil {
+			node.Index, err = q.expr(node.Index)
+		}
+	case *ast.IndexListExpr:
+		if node.X, err = q.expr(node.X); err == nil {
+			err = q.exprs(node.Indices)
+		}
+	case *ast.FuncType:
+		if err = q.fields(node.Params); err == nil {
+			err = q.fields(node.Results)
+		}
+	case *ast.StructType:
+		err = q.fields(node.Fields)
+	case *ast.InterfaceType:
+		err = q.fields(node.Methods)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// importPath resolves the import path of the package in dir using the closest go.mod
+func importPath(dir string) (string, error) {
+	for root := dir; ; {
+		data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			modulePath := modfile.ModulePath(data)
+			if modulePath == "" {
+				return "", fmt.Errorf("%s has no module directive", filepath.Join(root, "go.mod"))
+			}
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(modulePath, filepath.ToSlash(rel)), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", fmt.Errorf("no go.mod found for %s, it is needed to import the package from another one", dir)
+		}
+		root = parent
+	}
+}
+
+// packageDecls lists the package level names of the package of the parsed file, with the specs of the types
+// among them. the ones of the parsed file are read from its AST, the other non test files of its directory
+// which are not generated only on first use, since every one of them is parsed for it. the specs of those
+// belong to the cached files and are only read
+func (tv *TypeVisitor) packageDecls() (scope, map[string]*ast.TypeSpec, error) {
+	if !tv.declsLoaded {
+		tv.declsLoaded = true
+		tv.declared, tv.typeSpecs, tv.declsErr = tv.loader.packageDecls(filepath.Dir(tv.fileAbsPath), tv.packageName, tv.fileAbsPath)
+		if tv.declsErr == nil {
+			for name, spec := range tv.fileSpecs {
+				tv.typeSpecs[name] = spec
+			}
+			for name := range tv.fileDeclared {
+				tv.declared.add(name)
+			}
+		}
+	}
+	return tv.declared, tv.typeSpecs, tv.declsErr
+}
+
+// packageDecls lists the declarations of the files of pkg in dir besides parsed, see TypeVisitor.packageDecls
+func (l *Loader) packageDecls(dir string, pkg string, parsed string) (scope, map[string]*ast.TypeSpec, error) {
+	goFiles, err := l.GoFiles(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	declared := make(scope)
+	typeSpecs := make(map[string]*ast.TypeSpec)
+	for _, goFile := range goFiles {
+		if goFile == parsed {
+			continue
+		}
+		file, err := l.Cached(goFile)
+		if file != nil && ast.IsGenerated(file) {
+			continue // even when it does not parse, like a stale wrapper
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if file.Name.Name == pkg {
+			addDecls(file.Decls, declared, typeSpecs)
+		}
+	}
+	return declared, typeSpecs, nil
+}
+
+// addDecls adds the package level names of decls to declared, and the specs of the types among them to typeSpecs
+func addDecls(decls []ast.Decl, declared scope, typeSpecs map[string]*ast.TypeSpec) {
+	for _, decl := range decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				declared.add(spec.Name.Name)
+				typeSpecs[spec.Name.Name] = spec
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					declared.add(name.Name)
+				}
+			}
+		}
+	}
+	delete(declared, "_")
+}
@@ -0,0 +1,115 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputPackageWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("output.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Accounts"})
+	typeVisitor.SetOutput("tracing", "")
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+
+	assert.Equal(t, "test_samples.Accounts", interfaces[0].QualifiedName)
+	assert.Equal(t, "tracing", interfaces[0].OutPackage)
+	assert.Equal(t, filepath.Join(filepath.Dir(abs), "tracing"), interfaces[0].OutDirectory)
+	assert.Equal(t, "test_samples", interfaces[0].Imports["github.com/pm1381/sirish/internal/visitors/test_samples"])
+
+	methods := interfaces[0].Methods
+	require.Len(t, methods, 3)
+	assert.Equal(t, "*test_samples.Account", methods[0].Results[0].Type)
+	assert.Equal(t, "map[string][]test_samples.Account", methods[1].Params[1].Type)
+	assert.Equal(t, "[test_samples.Shards]int", methods[1].Params[2].Type)
+	assert.Equal(t, "func(test_samples.Account) error", methods[2].Params[1].Type)
+	assert.Equal(t, "<-chan test_samples.Account", methods[2].Results[0].Type)
+	assert.Equal(t, "string", methods[0].Params[1].Type)
+	assert.Equal(t, "error", methods[0].Results[1].Type)
+}
+
+func TestOutputPackageDefaultsWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("output.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Accounts"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+	assert.Equal(t, "Accounts", interfaces[0].QualifiedName)
+	assert.Equal(t, "test_samples", interfaces[0].OutPackage)
+	assert.Equal(t, filepath.Dir(abs), interfaces[0].OutDirectory)
+	assert.Equal(t, "*Account", interfaces[0].Methods[0].Results[0].Type)
+
+	typeVisitor = NewTypeVisitor(abs, dto.Types{"Accounts"})
+	typeVisitor.SetOutput("", "tracing")
+	err = typeVisitor.Traverse()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(abs), "tracing"), typeVisitor.GetWrappedInterfaces()[0].OutDirectory)
+}
+
+func TestOutputPackageUnexportedFails(t *testing.T) {
+	scenarios := map[string]string{
+		"Roles":    "Roles refers to unexported role",
+		"accounts": "interface accounts is unexported",
+		"Sealed":   "method seal of Sealed is unexported",
+	}
+	abs := internal.GetTestPathHelper("output.go", "visitors")
+	for iface, want := range scenarios {
+		t.Run(iface, func(t *testing.T) {
+			typeVisitor := NewTypeVisitor(abs, dto.Types{iface})
+			typeVisitor.SetOutput("tracing", "")
+			err := typeVisitor.Traverse()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), want)
+			assert.Contains(t, err.Error(), "output.go:")
+		})
+	}
+}
+
+func TestOutputPackageMismatchFails(t *testing.T) {
+	abs := internal.GetTestPathHelper("output.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Accounts"})
+	typeVisitor.SetOutput(".", "tracing")
+	err := typeVisitor.Traverse()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "differs from package test_samples")
+}
+
+func TestPackageDeclsAreLoadedOnDemand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("go.mod", "module example.com/m\n")
+	write("a.go", "package m\n\nimport \"context\"\n\ntype Store interface {\n\tGet(ctx context.Context, id string) (*Record, error)\n}\n\n"+
+		"type Values interface {\n\tValue(ctx context.Context) (Record, error)\n}\n")
+	write("record.go", "package m\n\ntype Record struct{}\n")
+	write("wrapper.sirish.go", "// Code generated by github.com/pm1381/sirish. DO NOT EDIT.\n\npackage m\n\nfunc (\n")
+	write("broken.go", "package m\n\nfunc (\n")
+	abs := filepath.Join(dir, "a.go")
+
+	// qualifying the types of the package needs none of its other files
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Store"})
+	typeVisitor.SetOutput("tracing", "")
+	require.NoError(t, typeVisitor.Traverse())
+	assert.Equal(t, "*m.Record", typeVisitor.GetWrappedInterfaces()[0].Methods[0].Results[0].Type)
+
+	// the zero value of Record does, they are parsed then
+	typeVisitor = NewTypeVisitor(abs, dto.Types{"Values"})
+	err := typeVisitor.Traverse()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken.go")
+
+	// without generated files
+	require.NoError(t, os.Remove(filepath.Join(dir, "broken.go")))
+	typeVisitor = NewTypeVisitor(abs, dto.Types{"Values"})
+	require.NoError(t, typeVisitor.Traverse())
+	assert.Equal(t, "Record{}", typeVisitor.GetWrappedInterfaces()[0].Methods[0].Results[0].Zero)
+}
@@ -0,0 +1,28 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	structs := internal.GetTestPathHelper("struct.go", "visitors")
+	typeVisitor := NewTypeVisitor(structs, nil)
+	typeVisitor.SetStructs([]dto.StructTarget{{Name: "UserService", Interface: "UserServiceInterface"}})
+	require.NoError(t, typeVisitor.Traverse())
+	userService := typeVisitor.GetWrappedInterfaces()[0]
+	// the generated interface is positioned at its struct and its methods at their declarations
+	assert.Equal(t, dto.Position{File: structs, Line: 9, Column: 6}, userService.Position)
+	assert.Equal(t, dto.Position{File: structs, Line: 13, Column: 1}, userService.Methods[0].Position)
+
+	funcs := internal.GetTestPathHelper("func_types.go", "visitors")
+	typeVisitor = NewTypeVisitor(funcs, dto.Types{"Handler"})
+	require.NoError(t, typeVisitor.Traverse())
+	handler := typeVisitor.GetWrappedInterfaces()[0]
+	assert.Equal(t, funcs, handler.Position.File)
+	assert.Equal(t, handler.Position.Line, handler.Methods[0].Position.Line)
+	assert.Greater(t, handler.Methods[0].Position.Column, handler.Position.Column)
+}
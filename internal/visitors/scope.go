@@ -0,0 +1,316 @@
+package visitors
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/internal/dto"
+	"go/ast"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	APMPath     = "go.elastic.co/apm/v2"
+	ContextPath = "context"
+	SyncPath    = "sync"
+	AtomicPath  = "sync/atomic"
+)
+
+// scope is a set of identifiers which are already taken
+type scope map[string]struct{}
+
+func (s scope) add(names ...string) {
+	for _, name := range names {
+		s[name] = struct{}{}
+	}
+}
+
+func (s scope) has(name string) bool {
+	_, ok := s[name]
+	return ok
+}
+
+// fresh returns base, or base followed by the first number making it unique, and takes it
+func (s scope) fresh(base string) string {
+	name := base
+	for i := 1; s.has(name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+	s.add(name)
+	return name
+}
+
+func (s scope) clone() scope {
+	res := make(scope, len(s))
+	for name := range s {
+		res.add(name)
+	}
+	return res
+}
+
+// handleScope runs before the traverse. it renames the imports which a parameter or result of a target
+// shadows inside wrapper bodies, rewriting every qualified type of the targets, and picks names for the
+// packages wrappers refer to which collide with nothing
+func (tv *TypeVisitor) handleScope(file *ast.File) error {
+	params := make(scope)
+	var targets []*ast.InterfaceType
+	var specs []*ast.TypeSpec
+	ast.Inspect(file, func(node ast.Node) bool {
+		typeSpec, ok := node.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		interfaceType, ok := typeSpec.Type.(*ast.InterfaceType)
+		if ok && tv.targetInterfaces.Exists(typeSpec.Name.Name) {
+			targets = append(targets, interfaceType)
+			specs = append(specs, typeSpec)
+			params.add(fieldNames(interfaceType)...)
+		}
+		return false
+	})
+
+	tv.fileScope = params.clone()
+	aliases := make(map[string]string)   // path to alias
+	unaliased := make(map[string]string) // path to the guessed name
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return err
+		}
+		alias := importName(importPath)
+		if spec.Name != nil {
+			alias = spec.Name.Name
+		}
+		if alias == "_" || alias == "." {
+			continue
+		}
+		if spec.Name == nil {
+			unaliased[importPath] = alias
+		}
+		tv.fileScope.add(alias)
+		aliases[importPath] = alias
+	}
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		alias, ok := aliases[importPath]
+		if !ok || !params.has(alias) {
+			continue
+		}
+		renamed := tv.fileScope.fresh(alias)
+		for _, target := range targets {
+			renameQualifier(target, alias, renamed)
+		}
+		spec.Name = ast.NewIdent(renamed)
+		aliases[importPath] = renamed
+	}
+
+	tv.qualifiers = make(map[string]string, len(aliases))
+	for importPath, alias := range aliases {
+		tv.qualifiers[alias] = importPath
+	}
+	if err := tv.resolveGuesses(targets, unaliased); err != nil {
+		return err
+	}
+	if err := tv.handleOutput(file, specs); err != nil {
+		return err
+	}
+
+	pick := func(importPath string, name string) string {
+		if alias, ok := aliases[importPath]; ok {
+			return alias
+		}
+		if tv.srcAlias != "" && importPath == tv.srcPath {
+			return tv.srcAlias // wrapping an interface of the package itself
+		}
+		return tv.fileScope.fresh(name)
+	}
+	tv.pkgs = dto.PkgNames{
+		Apm:     pick(APMPath, "apm"),
+		Context: pick(ContextPath, "context"),
+		Sync:    pick(SyncPath, "sync"),
+		Atomic:  pick(AtomicPath, "atomic"),
+	}
+	return nil
+}
+
+// resolveGuesses fixes the names guessed for imports without alias, like jsoniter for github.com/json-iterator/go.
+// when a qualifier of the targets matches no import, the unaliased imports nothing refers to are loaded to
+// read the names their packages declare
+func (tv *TypeVisitor) resolveGuesses(targets []*ast.InterfaceType, unaliased map[string]string) error {
+	used := make(scope)
+	for _, target := range targets {
+		used.add(qualifiers(target)...)
+	}
+	var unknown []string
+	for name := range used {
+		if _, ok := tv.qualifiers[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	var unused []string
+	for importPath, guess := range unaliased {
+		if !used.has(guess) {
+			unused = append(unused, importPath)
+		}
+	}
+	if len(unknown) == 0 || len(unused) == 0 {
+		return nil
+	}
+	names, err := tv.loader.PackageNames(filepath.Dir(tv.fileAbsPath), unused...)
+	if err != nil {
+		return fmt.Errorf("resolving the package names of the imports of %s: %w", tv.fileAbsPath, err)
+	}
+	for _, importPath := range unused {
+		name, ok := names[importPath]
+		if !ok || !slices.Contains(unknown, name) {
+			continue
+		}
+		delete(tv.qualifiers, unaliased[importPath])
+		tv.qualifiers[name] = importPath
+		tv.fileScope.add(name)
+	}
+	return nil
+}
+
+// handleUsedImports lists the packages a target refers to with the name it uses for them, so wrappers
+// import nothing else and need no formatting pass to compile
+func (tv *TypeVisitor) handleUsedImports(node ast.Node) (dto.PkgImports, error) {
+	res := make(dto.PkgImports)
+	for _, name := range qualifiers(node) {
+		importPath, ok := tv.qualifiers[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot find the import of package %s used by %s", name, tv.fileAbsPath)
+		}
+		res[importPath] = name
+	}
+	for importPath, alias := range tv.importAlias {
+		if alias == "." {
+			res[importPath] = alias // dot imports are used without any qualifier
+		}
+	}
+	return res, nil
+}
+
+// handleLocals names the identifiers a wrapper method declares so they shadow none of its params,
+// results or the packages of the file
+func (tv *TypeVisitor) handleLocals(method *dto.Method) {
+	methodScope := tv.fileScope.clone()
+	for _, param := range method.Params {
+		methodScope.add(param.Name)
+	}
+	for _, result := range method.Results {
+		methodScope.add(result.Name)
+	}
+	methodScope.add(method.CtxName)
+	if methodScope.has(method.SpanName) {
+		method.SpanName = methodScope.fresh(method.SpanName)
+	}
+	methodScope.add(method.SpanName)
+
+	method.Locals = dto.LocalNames{
+		PkgNames:   tv.pkgs,
+		Receiver:   methodScope.fresh("w"),
+		Tx:         methodScope.fresh("tx"),
+		StreamOnce: methodScope.fresh("streamOnce"),
+		StreamEnd:  methodScope.fresh("streamEnd"),
+		StreamIn:   methodScope.fresh("streamIn"),
+		StreamOut:  methodScope.fresh("streamOut"),
+		Items:      methodScope.fresh("items"),
+		Item:       methodScope.fresh("item"),
+		Key:        methodScope.fresh("key"),
+		Ok:         methodScope.fresh("ok"),
+		Exhausted:  methodScope.fresh("exhausted"),
+		Yield:      methodScope.fresh("yield"),
+		Returned:   methodScope.fresh("returned"),
+		Links:      methodScope.fresh("links"),
+	}
+	if method.Closer != nil {
+		method.Locals.Wrapper = methodScope.fresh(method.Closer.Result + "Wrapper")
+	}
+}
+
+// isContext checks a printable type is context.Context, whatever the context package is called in the file
+func (tv *TypeVisitor) isContext(typeStr string) bool {
+	return typeStr == tv.pkgs.Context+".Context"
+}
+
+// importName guesses the name of an import without alias from its path, skipping major version
+// suffixes like go.elastic.co/apm/v2 and gopkg.in/yaml.v3
+func importName(importPath string) string {
+	name := path.Base(importPath)
+	if isMajorVersion(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	if index := strings.Index(name, ".v"); index > 0 && isMajorVersion(name[index+1:]) {
+		name = name[:index]
+	}
+	// same guess as goimports: go-redis is redis and anything after the first invalid rune is dropped
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "go-"), "-go")
+	if index := strings.IndexFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); index > 0 {
+		name = name[:index]
+	}
+	return name
+}
+
+func isMajorVersion(element string) bool {
+	if len(element) < 2 || element[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(element[1:])
+	return err == nil
+}
+
+// fieldNames lists the names of every param and result of the interface methods
+func fieldNames(interfaceType *ast.InterfaceType) []string {
+	var res []string
+	for _, method := range interfaceType.Methods.List {
+		funcType, ok := method.Type.(*ast.FuncType)
+		if !ok {
+			continue
+		}
+		for _, fieldList := range []*ast.FieldList{funcType.Params, funcType.Results} {
+			if fieldList == nil {
+				continue
+			}
+			for _, field := range fieldList.List {
+				for _, name := range field.Names {
+					if name.Name != "_" {
+						res = append(res, name.Name)
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+// qualifiers lists the package names of every pkg.Type selector in node
+func qualifiers(node ast.Node) []string {
+	var res []string
+	ast.Inspect(node, func(n ast.Node) bool {
+		if selector, ok := n.(*ast.SelectorExpr); ok {
+			if pkg, ok := selector.X.(*ast.Ident); ok {
+				res = append(res, pkg.Name)
+			}
+		}
+		return true
+	})
+	return res
+}
+
+// renameQualifier rewrites pkg.Type selectors of node, inside types a selector is always a qualified identifier
+func renameQualifier(node ast.Node, from string, to string) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if selector, ok := n.(*ast.SelectorExpr); ok {
+			if pkg, ok := selector.X.(*ast.Ident); ok && pkg.Name == from {
+				pkg.Name = to
+			}
+		}
+		return true
+	})
+}
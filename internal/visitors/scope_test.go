@@ -0,0 +1,57 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestImportNameWorks(t *testing.T) {
+	scenarios := map[string]string{
+		"context":                           "context",
+		"math/rand":                         "rand",
+		"go.elastic.co/apm/v2":              "apm",
+		"gopkg.in/yaml.v3":                  "yaml",
+		"github.com/redis/go-redis/v9":      "redis",
+		"github.com/pm1381/sirish/internal": "internal",
+		"github.com/mattn/go-isatty":        "isatty",
+	}
+	for importPath, want := range scenarios {
+		t.Run(importPath, func(t *testing.T) {
+			assert.Equal(t, want, importName(importPath))
+		})
+	}
+}
+
+func TestScopeFreshWorks(t *testing.T) {
+	s := make(scope)
+	s.add("w", "w1")
+	assert.Equal(t, "w2", s.fresh("w"))
+	assert.Equal(t, "w3", s.fresh("w"))
+	assert.Equal(t, "tx", s.fresh("tx"))
+	assert.True(t, s.has("tx"))
+}
+
+func TestShadowedNamesWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("shadowed.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Shadowed"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+
+	// a param called context renames the context import and every type using it
+	assert.Equal(t, dto.PkgNames{Apm: "apm1", Context: "context1", Sync: "sync", Atomic: "atomic"}, interfaces[0].Pkgs)
+	assert.Equal(t, "context1", typeVisitor.GetImports()["context"])
+
+	methods := interfaces[0].Methods
+	require.Len(t, methods, 2)
+	assert.True(t, methods[0].HasCtx)
+	assert.Equal(t, "context1.Context", methods[0].Params[0].Type)
+	assert.Equal(t, "w1", methods[0].Locals.Receiver)
+	assert.Equal(t, "tx1", methods[0].Locals.Tx)
+	assert.Equal(t, "apm1", methods[1].Locals.Apm)
+	assert.Equal(t, "streamIn1", methods[1].Locals.StreamIn)
+}
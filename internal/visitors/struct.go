@@ -0,0 +1,265 @@
+package visitors
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/internal/dto"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeneratedHeader starts every file sirish writes
+const GeneratedHeader = "Code generated by github.com/pm1381/sirish."
+
+// SetStructs adds structs whose exported method set is turned into an interface and wrapped
+func (tv *TypeVisitor) SetStructs(structs []dto.StructTarget) {
+	tv.structs = make(map[string]string, len(structs))
+	for _, eachStruct := range structs {
+		tv.structs[eachStruct.Interface] = eachStruct.Name
+		tv.targetInterfaces = append(tv.targetInterfaces, eachStruct.Interface)
+	}
+	tv.needMultipleFiles = len(tv.targetInterfaces) > 1
+}
+
+// handleStructs declares an interface for every target struct in file, built from the exported method set
+// of a pointer to it. the methods the package declares on it are taken from their syntax, the ones promoted
+// from its embedded fields from the package type checked by the loader. the imports those methods use are
+// added to file
+func (tv *TypeVisitor) handleStructs(file *ast.File) error {
+	if len(tv.structs) == 0 {
+		return nil
+	}
+	files := []*ast.File{file}
+	paths := map[*ast.File]string{file: tv.fileAbsPath}
+	goFiles, err := tv.loader.GoFiles(filepath.Dir(tv.fileAbsPath))
+	if err != nil {
+		return err
+	}
+	for _, goFile := range goFiles {
+		if goFile == tv.fileAbsPath {
+			continue
+		}
+		other, err := tv.loader.Cached(goFile)
+		if err != nil {
+			return err
+		}
+		if other.Name.Name == file.Name.Name && !IsGenerated(other) {
+			files = append(files, other)
+			paths[other] = goFile
+		}
+	}
+
+	declared := make(scope)
+	specs := make(map[string]*ast.TypeSpec) // the generated interfaces are positioned at their structs
+	for _, eachFile := range files {
+		for _, decl := range eachFile.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					declared.add(typeSpec.Name.Name)
+					specs[typeSpec.Name.Name] = typeSpec
+				}
+			}
+		}
+	}
+
+	ifaceNames := make([]string, 0, len(tv.structs))
+	for ifaceName := range tv.structs {
+		ifaceNames = append(ifaceNames, ifaceName)
+	}
+	sort.Strings(ifaceNames)
+	for _, ifaceName := range ifaceNames {
+		structName := tv.structs[ifaceName]
+		if !declared.has(structName) {
+			return fmt.Errorf("struct %s is not declared in package %s", structName, file.Name.Name)
+		}
+		if declared.has(ifaceName) {
+			return fmt.Errorf("interface %s of struct %s is already declared in package %s", ifaceName, structName, file.Name.Name)
+		}
+		var methods []*ast.Field
+		for _, eachFile := range files {
+			fileMethods, err := structMethods(eachFile, structName)
+			if err != nil {
+				return err
+			}
+			if len(fileMethods) > 0 && eachFile != file {
+				// merging renames qualifiers of the methods, which must not change the cached file
+				if eachFile, err = tv.loader.Parse(paths[eachFile]); err != nil {
+					return err
+				}
+				if fileMethods, err = structMethods(eachFile, structName); err != nil {
+					return err
+				}
+				if err = mergeImports(file, eachFile, structName, fileMethods); err != nil {
+					return err
+				}
+			}
+			methods = append(methods, fileMethods...)
+		}
+		promoted, err := tv.promotedMethods(file, specs[structName], methods)
+		if err != nil {
+			return err
+		}
+		methods = append(methods, promoted...)
+		if len(methods) == 0 {
+			return fmt.Errorf("struct %s has no exported methods to wrap", structName)
+		}
+		file.Decls = append(file.Decls, &ast.GenDecl{
+			Tok: token.TYPE,
+			Specs: []ast.Spec{&ast.TypeSpec{
+				Name: &ast.Ident{Name: ifaceName, NamePos: specs[structName].Name.Pos()},
+				Type: &ast.InterfaceType{Methods: &ast.FieldList{List: methods}},
+			}},
+		})
+	}
+	return nil
+}
+
+// IsGenerated reports whether file is written by sirish, its header is in the first comment
+func IsGenerated(file *ast.File) bool {
+	return len(file.Comments) > 0 && strings.HasPrefix(file.Comments[0].Text(), GeneratedHeader)
+}
+
+// promotedMethods lists the exported methods the struct of spec gets from its embedded fields, leaving out
+// the ones it declares. the package is only type checked when the struct embeds a field
+func (tv *TypeVisitor) promotedMethods(file *ast.File, spec *ast.TypeSpec, declared []*ast.Field) ([]*ast.Field, error) {
+	structType, ok := spec.Type.(*ast.StructType)
+	if !ok || structType.Fields == nil || !slices.ContainsFunc(structType.Fields.List, func(field *ast.Field) bool {
+		return len(field.Names) == 0
+	}) {
+		return nil, nil
+	}
+	pkg, err := tv.loader.Types(tv.fileAbsPath)
+	if err != nil {
+		return nil, err
+	}
+	typeName, ok := pkg.Scope().Lookup(spec.Name.Name).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("package %s has no declaration of %s", pkg.Path(), spec.Name.Name)
+	}
+	names := make(scope)
+	for _, field := range declared {
+		names.add(field.Names[0].Name)
+	}
+	qualify, err := tv.importer(file)
+	if err != nil {
+		return nil, err
+	}
+	var methods []*ast.Field
+	methodSet := types.NewMethodSet(types.NewPointer(typeName.Type()))
+	for i := 0; i < methodSet.Len(); i++ {
+		method := methodSet.At(i).Obj().(*types.Func)
+		if !method.Exported() || names.has(method.Name()) {
+			continue
+		}
+		field, err := tv.methodField(method, qualify(pkg), tv.loader.FileSet())
+		if err != nil {
+			return nil, fmt.Errorf("method %s promoted to %s: %w", method.Name(), spec.Name.Name, err)
+		}
+		methods = append(methods, field)
+	}
+	return methods, nil
+}
+
+// structMethods lists the exported methods file declares on structName as interface fields
+func structMethods(file *ast.File, structName string) ([]*ast.Field, error) {
+	var methods []*ast.Field
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 || !funcDecl.Name.IsExported() {
+			continue
+		}
+		recv := funcDecl.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		switch recvType := recv.(type) {
+		case *ast.Ident:
+			if recvType.Name != structName {
+				continue
+			}
+		case *ast.IndexExpr, *ast.IndexListExpr:
+			if name := typeName(recvType); name == structName {
+				return nil, fmt.Errorf("generic struct %s is not supported", structName)
+			}
+			continue
+		default:
+			continue
+		}
+		methods = append(methods, &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent(funcDecl.Name.Name)},
+			Type:  funcDecl.Type,
+		})
+	}
+	return methods, nil
+}
+
+func typeName(expr ast.Expr) string {
+	switch node := expr.(type) {
+	case *ast.IndexExpr:
+		return typeName(node.X)
+	case *ast.IndexListExpr:
+		return typeName(node.X)
+	case *ast.Ident:
+		return node.Name
+	}
+	return ""
+}
+
+// mergeImports adds the imports of other which methods use to file, renaming the qualifiers
+// of the methods to the names file already uses for the same packages
+func mergeImports(file *ast.File, other *ast.File, structName string, methods []*ast.Field) error {
+	names := make(map[string]string) // path to its name in file
+	paths := make(map[string]string) // name in file to its path
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return err
+		}
+		name := importName(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		names[importPath] = name
+		paths[name] = importPath
+	}
+	used := make(scope)
+	for _, method := range methods {
+		used.add(qualifiers(method.Type)...)
+	}
+	for _, spec := range other.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return err
+		}
+		name := importName(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if !used.has(name) {
+			continue
+		}
+		if existing, ok := names[importPath]; ok {
+			if existing != name {
+				for _, method := range methods {
+					renameQualifier(method.Type, name, existing)
+				}
+			}
+			continue
+		}
+		if existing, ok := paths[name]; ok {
+			return fmt.Errorf("methods of %s refer to %s as %s, which is %s in the parsed file", structName, importPath, name, existing)
+		}
+		merged := &ast.ImportSpec{Name: spec.Name, Path: spec.Path}
+		file.Imports = append(file.Imports, merged)
+		file.Decls = append([]ast.Decl{&ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{merged}}}, file.Decls...)
+		names[importPath] = name
+		paths[name] = importPath
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommentVisitorStructs(t *testing.T) {
+	pathAbs := internal.GetTestPathHelper("struct.go", "visitors")
+	commentModule := NewCommentVisitor(pathAbs)
+	commentModule.Traverse()
+
+	assert.Empty(t, commentModule.GetTargets())
+	assert.Equal(t, []dto.StructTarget{
+		{Name: "UserService", Interface: "UserServiceInterface"},
+		{Name: "Cache", Interface: "CacheAPI"},
+	}, commentModule.GetStructs())
+}
+
+func TestStructTargetsWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("struct.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, nil)
+	typeVisitor.SetStructs([]dto.StructTarget{{Name: "UserService", Interface: "UserServiceInterface"}})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+
+	userService := interfaces[0]
+	assert.Equal(t, "UserServiceInterface", userService.Name)
+	assert.Equal(t, "UserServiceInterface", userService.QualifiedName)
+	assert.Equal(t, "UserService", userService.Struct)
+	var names []string
+	for _, method := range userService.Methods {
+		names = append(names, method.Name)
+	}
+	// pointer and value receivers of every file, without the unexported refresh
+	assert.Equal(t, []string{"Get", "TTL", "Expire", "Link"}, names)
+	assert.Equal(t, "time.Time", userService.Methods[2].Params[1].Type) // stdtime is time in the parsed file
+	assert.Equal(t, "*url.URL", userService.Methods[3].Results[0].Type)
+	assert.Equal(t, dto.PkgImports{"context": "context", "time": "time", "net/url": "url"}, userService.Imports)
+	assert.Contains(t, userService.Declaration, "Expire(ctx context.Context, at time.Time) error")
+	assert.Contains(t, userService.Declaration, "TTL() time.Duration")
+}
+
+func TestStructTargetsOutputPackageWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("struct.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, nil)
+	typeVisitor.SetStructs([]dto.StructTarget{{Name: "UserService", Interface: "UserServiceInterface"}})
+	typeVisitor.SetOutput("tracing", "")
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+
+	// the interface is generated in the output package, the struct stays in its own
+	assert.Equal(t, "UserServiceInterface", interfaces[0].QualifiedName)
+	assert.Equal(t, "test_samples.UserService", interfaces[0].Struct)
+	assert.Equal(t, "*test_samples.Account", interfaces[0].Methods[0].Results[0].Type)
+	assert.Contains(t, interfaces[0].Declaration, "Get(ctx context.Context, id string) (*test_samples.Account, error)")
+}
+
+func TestStructTargetsPromotedMethods(t *testing.T) {
+	abs := internal.GetTestPathHelper("struct_embedded.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, nil)
+	typeVisitor.SetStructs([]dto.StructTarget{{Name: "AuditedService", Interface: "AuditedServiceInterface"}})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+
+	// the exported method set of *AuditedService, with the methods of *UserService and io.Closer
+	var names []string
+	methods := make(map[string]dto.Method)
+	for _, method := range interfaces[0].Methods {
+		names = append(names, method.Name)
+		methods[method.Name] = method
+	}
+	assert.Equal(t, []string{"Audit", "Close", "Expire", "Get", "Link", "TTL"}, names)
+	assert.Equal(t, "time.Time", methods["Expire"].Params[1].Type)
+	assert.Equal(t, "*url.URL", methods["Link"].Results[0].Type)
+	assert.Equal(t, "io.go", filepath.Base(methods["Close"].Position.File))
+	assert.Equal(t, "struct_methods.go", filepath.Base(methods["Link"].Position.File))
+	assert.Contains(t, interfaces[0].Declaration, "Close() error")
+	assert.Contains(t, interfaces[0].Imports, "net/url")
+}
+
+func TestStructTargetsFails(t *testing.T) {
+	scenarios := map[string]struct {
+		target dto.StructTarget
+		want   string
+	}{
+		"NoMethods":  {target: dto.StructTarget{Name: "Cache", Interface: "CacheAPI"}, want: "struct Cache has no exported methods"},
+		"Generic":    {target: dto.StructTarget{Name: "Box", Interface: "BoxInterface"}, want: "generic struct Box is not supported"},
+		"Missing":    {target: dto.StructTarget{Name: "Missing", Interface: "MissingInterface"}, want: "struct Missing is not declared"},
+		"NameIsUsed": {target: dto.StructTarget{Name: "UserService", Interface: "Accounts"}, want: "interface Accounts of struct UserService is already declared"},
+	}
+	abs := internal.GetTestPathHelper("struct.go", "visitors")
+	for name, s := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			typeVisitor := NewTypeVisitor(abs, nil)
+			typeVisitor.SetStructs([]dto.StructTarget{s.target})
+			err := typeVisitor.Traverse()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), s.want)
+		})
+	}
+}
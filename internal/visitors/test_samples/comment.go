@@ -34,6 +34,12 @@ type MagicNoParams interface {
 	Method3() (string, error)
 }
 
+// MagicPlain has no directive, it is only explained that the interfaces around it are marked with // sirish:Name
+// or //sirish:Name
+type MagicPlain interface {
+	Method1() // calls // sirish:Nothing
+}
+
 // sirish:MagicNoResult
 type MagicNoResult interface {
 	Method1(s []string)
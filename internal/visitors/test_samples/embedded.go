@@ -0,0 +1,17 @@
+package test_samples
+
+import (
+	"context"
+	"io"
+)
+
+// Repo gets Close from another package and Lookup from the same one only by embedding them
+type Repo interface {
+	io.Closer
+	AccountLookup
+	Save(ctx context.Context, account *Account) error
+}
+
+type AccountLookup interface {
+	Lookup(ctx context.Context, id string) (*Account, error)
+}
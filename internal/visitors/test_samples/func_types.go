@@ -0,0 +1,15 @@
+package test_samples
+
+import "context"
+
+type Message struct {
+	Body []byte
+}
+
+type Handler func(ctx context.Context, msg Message) error
+
+type Lookup func(key string) (value string, found bool)
+
+type Dispatcher interface {
+	Handler(ctx context.Context, topic string) (Handler, error)
+}
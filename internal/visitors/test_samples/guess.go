@@ -0,0 +1,7 @@
+package test_samples
+
+import "github.com/pm1381/sirish/internal/visitors/test_samples/randutil"
+
+type Guessed interface {
+	Method1(t random.Thing) *random.Thing
+}
@@ -0,0 +1,10 @@
+package test_samples
+
+import (
+	"github.com/pm1381/sirish/internal/visitors/test_samples/randutil"
+	"github.com/pm1381/sirish/internal/visitors/test_samples/wordutil"
+)
+
+type GuessedMany interface {
+	Method1(t random.Thing) words.Word
+}
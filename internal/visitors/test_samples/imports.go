@@ -8,3 +8,7 @@ import (
 type RandConflict interface {
 	Method1(t1 rand.Something, t2 rand2.Rand) rand2.Rand
 }
+
+type OnlyRand interface {
+	Method1(t1 rand.Something)
+}
@@ -0,0 +1,30 @@
+package test_samples
+
+import "context"
+
+type Account struct {
+	ID string
+}
+
+type role int
+
+const Shards = 4
+
+type Accounts interface {
+	Get(ctx context.Context, id string) (*Account, error)
+	List(ctx context.Context, filter map[string][]Account, shards [Shards]int) ([]*Account, error)
+	Watch(ctx context.Context, fn func(Account) error) (<-chan Account, error)
+}
+
+type Roles interface {
+	Role(ctx context.Context) (role, error)
+}
+
+type accounts interface {
+	Get(ctx context.Context, id string) (*Account, error)
+}
+
+type Sealed interface {
+	Get(ctx context.Context, id string) (*Account, error)
+	seal()
+}
@@ -0,0 +1,4 @@
+package random
+
+type Thing struct {
+}
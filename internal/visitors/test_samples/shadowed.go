@@ -0,0 +1,8 @@
+package test_samples
+
+import "context"
+
+type Shadowed interface {
+	Method1(context context.Context, w string, tx int) error
+	Method2(apm string, streamIn <-chan int) <-chan int
+}
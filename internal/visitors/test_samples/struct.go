@@ -0,0 +1,32 @@
+package test_samples
+
+import (
+	"context"
+	"time"
+)
+
+//sirish:struct UserService
+type UserService struct {
+	ttl time.Duration
+}
+
+func (s *UserService) Get(ctx context.Context, id string) (*Account, error) {
+	return nil, nil
+}
+
+func (s UserService) TTL() time.Duration {
+	return s.ttl
+}
+
+func (s *UserService) refresh() {}
+
+// sirish:struct Cache CacheAPI
+type Cache struct{}
+
+type Box[T any] struct {
+	value T
+}
+
+func (b *Box[T]) Get() T {
+	return b.value
+}
@@ -0,0 +1,13 @@
+package test_samples
+
+import "io"
+
+// AuditedService gets the methods of UserService and io.Closer only through its embedded fields
+type AuditedService struct {
+	*UserService
+	io.Closer
+}
+
+func (s *AuditedService) Audit(id string) error {
+	return nil
+}
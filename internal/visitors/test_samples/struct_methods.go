@@ -0,0 +1,15 @@
+package test_samples
+
+import (
+	"context"
+	"net/url"
+	stdtime "time"
+)
+
+func (s *UserService) Expire(ctx context.Context, at stdtime.Time) error {
+	return nil
+}
+
+func (s *UserService) Link(id string) *url.URL {
+	return nil
+}
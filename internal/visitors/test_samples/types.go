@@ -1,6 +1,10 @@
 package test_samples
 
-import "context"
+import (
+	"context"
+	"io"
+	"iter"
+)
 
 type NamedParamsAndResults interface {
 	Method1(a string, b *int, c []byte) (s string, err error)
@@ -17,3 +21,29 @@ type NoResult interface {
 	Method2(a, b, c int, s context.Context)
 	Method3(a, _, c int, _ *context.Context)
 }
+
+type StreamResults interface {
+	Method1(ctx context.Context) (<-chan string, error)
+	Method2() iter.Seq[*int]
+	Method3() (all iter.Seq2[string, []byte], err error)
+	Method4() func() (string, bool)
+	Method5() (chan string, func(int) (string, bool))
+}
+
+type CloserResults interface {
+	Method1(ctx context.Context) (io.ReadCloser, error)
+	Method2() (w io.Writer, c io.WriteCloser)
+	Method3() Closable
+	Method4() NoResult
+	Method5() NoParams
+}
+
+type Closable interface {
+	Close() error
+}
+
+type CallbackParams interface {
+	Method1(ctx context.Context, fn func(ctx context.Context, s string) error) error
+	Method2(fn func(context.Context, ...int) (int, bool))
+	Method3(fn func(s string) error, _ func(context.Context))
+}
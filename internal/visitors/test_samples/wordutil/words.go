@@ -0,0 +1,3 @@
+package words
+
+type Word string
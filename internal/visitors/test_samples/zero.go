@@ -0,0 +1,53 @@
+package test_samples
+
+import (
+	"context"
+	"time"
+)
+
+type ZeroID int64
+
+type ZeroName string
+
+type ZeroFlag bool
+
+type ZeroMeters ZeroID
+
+type ZeroPoint struct {
+	X, Y int
+}
+
+type ZeroGrid [2][2]int
+
+type ZeroTags []string
+
+type ZeroIndex map[string]int
+
+type ZeroCallback func()
+
+type ZeroRef *ZeroPoint
+
+type ZeroAlias = ZeroPoint
+
+type ZeroLocation ZeroPoint
+
+type ZeroPage[T any] struct {
+	Items []T
+}
+
+type ZeroReader interface {
+	Read() error
+}
+
+type Zeros interface {
+	Basic(ctx context.Context) (int, string, bool, error)
+	Named(ctx context.Context) (ZeroID, ZeroName, ZeroFlag, ZeroMeters)
+	Composite(ctx context.Context) (ZeroPoint, ZeroGrid, ZeroTags, ZeroIndex, ZeroCallback, ZeroRef)
+	Defined(ctx context.Context) (ZeroAlias, ZeroLocation, *ZeroPoint, ZeroPage[ZeroPoint], ZeroReader)
+	Other(ctx context.Context) (time.Time, context.Context, [3]byte)
+	Callback(ctx context.Context, fn func(ctx context.Context) (ZeroID, error)) error
+}
+
+type GenericZeros[T any, K comparable] interface {
+	Get(ctx context.Context, key K) (T, []T, error)
+}
@@ -4,11 +4,10 @@ import (
 	"fmt"
 	"github.com/pm1381/sirish/internal/dto"
 	"go/ast"
-	"go/parser"
 	"go/token"
-	"log"
 	"path"
 	"strconv"
+	"strings"
 )
 
 type TypeVisitor struct {
@@ -19,6 +18,28 @@ type TypeVisitor struct {
 	importAlias       dto.PkgImports
 	wrappedInterfaces []dto.InterfaceInfo
 	needMultipleFiles bool
+	fileScope         scope             // params, results and imports of the targets in the file
+	qualifiers        map[string]string // package names used in the file to their import path
+	pkgs              dto.PkgNames      // names of the packages wrappers refer to
+	outDir            string            // requested output directory
+	outPkg            string            // requested output package
+	outDirectory      string            // resolved directory the wrappers are written to
+	outPackage        string            // resolved package of the wrappers
+	srcAlias          string            // name the output package refers to the source package with
+	srcPath           string            // import path of the parsed file, resolved from go.mod when needed
+	structs           map[string]string // generated interfaces to the structs they are extracted from
+	funcs             scope             // target function types, parsed as single method interfaces
+	declared          scope             // package level names of the package of the parsed file, see packageDecls
+	typeSpecs         map[string]*ast.TypeSpec
+	fileDeclared      scope // package level names of the parsed file, in declared once it is loaded
+	fileSpecs         map[string]*ast.TypeSpec
+	declsLoaded       bool
+	declsErr          error
+	typeParams        scope // type params of the interface being visited
+	loader            *Loader
+	methodSets        map[string]MethodSet        // method sets of the targets embedding other interfaces
+	embedded          map[*ast.Field]dto.Position // methods added from go/types, embedded or promoted, to where they are declared
+	err               error                       // first error of the walk, which stops at it
 }
 
 func NewTypeVisitor(fileAbsPath string, targets dto.Types) *TypeVisitor {
@@ -31,14 +52,59 @@ func NewTypeVisitor(fileAbsPath string, targets dto.Types) *TypeVisitor {
 	}
 }
 
+// NewExternalTypeVisitor parses a file of another package, whose wrappers always go into their own files
+func NewExternalTypeVisitor(fileAbsPath string, importPath string, targets dto.Types) *TypeVisitor {
+	tv := NewTypeVisitor(fileAbsPath, targets)
+	tv.srcPath = importPath
+	tv.needMultipleFiles = true
+	return tv
+}
+
+// SetLoader shares the parsed files of a run, without one every Traverse parses on its own
+func (tv *TypeVisitor) SetLoader(loader *Loader) {
+	tv.loader = loader
+}
+
 func (tv *TypeVisitor) Traverse() error {
-	fSet := token.NewFileSet()
-	file, err := parser.ParseFile(fSet, tv.fileAbsPath, nil, parser.ParseComments)
+	if tv.loader == nil {
+		tv.loader = NewLoader()
+	}
+	file, err := tv.loader.Parse(tv.fileAbsPath)
 	if err != nil {
 		return err
 	}
-	tv.fSet = fSet
+	return tv.TraverseFile(file)
+}
+
+// TraverseFile extracts the targets of file, parsed with Parse of the loader of the visitor. the AST is
+// changed on the way, so it can not be shared with another TypeVisitor
+func (tv *TypeVisitor) TraverseFile(file *ast.File) error {
+	if tv.loader == nil {
+		tv.loader = NewLoader()
+	}
+	tv.fSet = tv.loader.FileSet()
+	tv.packageName = file.Name.Name
+	tv.fileDeclared, tv.fileSpecs = make(scope), make(map[string]*ast.TypeSpec)
+	addDecls(file.Decls, tv.fileDeclared, tv.fileSpecs) // before the targets of structs are declared in it
+	tv.handleFuncs(file)
+	var err error
+	if err = tv.handleStructs(file); err != nil {
+		return err
+	}
+	if err = tv.handleEmbedded(file); err != nil {
+		return err
+	}
+	if err = tv.handleScope(file); err != nil {
+		return err
+	}
 	ast.Walk(tv, file)
+	if tv.err != nil {
+		return tv.err
+	}
+	if tv.declsErr != nil {
+		return tv.declsErr // a zero value needed the declarations of the package
+	}
+	tv.resolveTargetClosers()
 	return nil
 }
 
@@ -51,7 +117,7 @@ func (tv *TypeVisitor) GetImports() dto.PkgImports {
 }
 
 func (tv *TypeVisitor) Visit(node ast.Node) ast.Visitor {
-	if node == nil {
+	if node == nil || tv.err != nil {
 		return nil
 	}
 
@@ -60,9 +126,8 @@ func (tv *TypeVisitor) Visit(node ast.Node) ast.Visitor {
 	case *ast.File:
 		tv.packageName = nodeWithType.Name.Name
 	case *ast.ImportSpec:
-		err := tv.handleImports(nodeWithType)
-		if err != nil {
-			log.Fatal(err)
+		if tv.err = tv.handleImports(nodeWithType); tv.err != nil {
+			return nil
 		}
 	case *ast.TypeSpec:
 		switch interfaceType := nodeWithType.Type.(type) {
@@ -85,16 +150,36 @@ func (tv *TypeVisitor) Visit(node ast.Node) ast.Visitor {
 				Package:   tv.packageName,
 				Directory: path.Dir(tv.fileAbsPath),
 				Methods:   nil,
+				Pkgs:      tv.pkgs,
+
+				QualifiedName: interfaceName,
+				OutPackage:    tv.outPackage,
+				OutDirectory:  tv.outDirectory,
+				Func:          tv.funcs.has(interfaceName),
+				Position:      tv.position(nodeWithType.Name),
+			}
+			structName, generated := tv.structs[interfaceName]
+			if tv.srcAlias != "" && !generated {
+				interfaceInfo.QualifiedName = tv.srcAlias + "." + interfaceName
 			}
+			if generated {
+				interfaceInfo.Declaration = ExprToString(tv.fSet, interfaceType)
+				interfaceInfo.Struct = structName
+				if tv.srcAlias != "" {
+					interfaceInfo.Struct = tv.srcAlias + "." + structName
+				}
+			}
+			tv.typeParams = make(scope)
 			if nodeWithType.TypeParams != nil {
-				err := tv.handleGenerics(nodeWithType.TypeParams, &interfaceInfo)
-				if err != nil {
-					log.Fatal(err)
+				if tv.err = tv.handleGenerics(nodeWithType.TypeParams, &interfaceInfo); tv.err != nil {
+					return nil
 				}
 			}
-			err := tv.handleInterface(interfaceType, &interfaceInfo)
-			if err != nil {
-				log.Fatal(err)
+			if tv.err = tv.handleInterface(interfaceType, &interfaceInfo); tv.err != nil {
+				return nil
+			}
+			if interfaceInfo.Imports, tv.err = tv.handleUsedImports(nodeWithType); tv.err != nil {
+				return nil
 			}
 			tv.wrappedInterfaces = append(tv.wrappedInterfaces, interfaceInfo)
 			return nil // no need to check this interface children
@@ -120,6 +205,10 @@ func (tv *TypeVisitor) handleInterface(node *ast.InterfaceType, interfaceDto *dt
 				Params:      nil,
 				Results:     nil,
 				SpanName:    "span",
+				Position:    tv.position(method),
+			}
+			if interfaceDto.Func {
+				methodInfo.SpecialName = interfaceDto.Name
 			}
 			errParam := tv.handleParams(functionWithType.Params, &methodInfo)
 			if errParam != nil {
@@ -129,6 +218,10 @@ func (tv *TypeVisitor) handleInterface(node *ast.InterfaceType, interfaceDto *dt
 			if errRes != nil {
 				return errRes
 			}
+			tv.handleLocals(&methodInfo)
+			if isCloseMethod(methodInfo) {
+				interfaceDto.Closable = true
+			}
 			methods = append(methods, methodInfo)
 		}
 	}
@@ -136,6 +229,22 @@ func (tv *TypeVisitor) handleInterface(node *ast.InterfaceType, interfaceDto *dt
 	return nil
 }
 
+// position is where node starts, methods of structs are positioned at their declaration
+func (tv *TypeVisitor) position(node ast.Node) dto.Position {
+	pos := node.Pos()
+	if field, ok := node.(*ast.Field); ok && !pos.IsValid() {
+		if position, ok := tv.embedded[field]; ok {
+			return position
+		}
+		pos = field.Type.Pos()
+	}
+	if !pos.IsValid() {
+		return dto.Position{}
+	}
+	position := tv.fSet.Position(pos)
+	return dto.Position{File: position.Filename, Line: position.Line, Column: position.Column}
+}
+
 func (tv *TypeVisitor) handleGenerics(genericFieldList *ast.FieldList, interfaceDto *dto.InterfaceInfo) error {
 	if genericFieldList == nil || len(genericFieldList.List) == 0 {
 		return nil
@@ -150,6 +259,7 @@ func (tv *TypeVisitor) handleGenerics(genericFieldList *ast.FieldList, interface
 			constraint = "any"
 		}
 		for _, name := range field.Names {
+			tv.typeParams.add(name.Name)
 			typeParams = append(typeParams, dto.TypeParamInfo{
 				Name:       name.Name,
 				Constraint: constraint,
@@ -170,7 +280,7 @@ func (tv *TypeVisitor) handleParams(params *ast.FieldList, method *dto.Method) e
 			continue
 		}
 		typeStr := ExprToString(tv.fSet, p.Type)
-		if !method.HasCtx && (typeStr == "context.Context") {
+		if !method.HasCtx && tv.isContext(typeStr) {
 			method.HasCtx = true // handling first ctx occurs
 			if len(p.Names) == 0 {
 				ctxName := fmt.Sprintf("ctx_%d_0", index)
@@ -207,23 +317,26 @@ func (tv *TypeVisitor) handleParams(params *ast.FieldList, method *dto.Method) e
 		}
 		if len(p.Names) == 0 {
 			paramsInfo = append(paramsInfo, dto.ParamInfo{
-				Name: MethodParamSnowflake(method.Name, index, 0, 4, "Un"),
-				Type: typeStr,
+				Name:     MethodParamSnowflake(method.Name, index, 0, 4, "Un"),
+				Type:     typeStr,
+				Callback: tv.handleCallback(p.Type, method, fmt.Sprintf("callback%d", index), index, 0),
 			})
 		} else {
 			for i, name := range p.Names {
 				if name.Name == "_" {
 					paramsInfo = append(paramsInfo, dto.ParamInfo{
-						Name: MethodParamSnowflake(method.Name, index, i, 4, "Un"),
-						Type: typeStr, // covering edge-cases like underscore(Us) params
+						Name:     MethodParamSnowflake(method.Name, index, i, 4, "Un"),
+						Type:     typeStr, // covering edge-cases like underscore(Us) params
+						Callback: tv.handleCallback(p.Type, method, fmt.Sprintf("callback%d", index), index, i),
 					})
 				} else {
 					if name.Name == method.SpanName {
 						method.SpanName = MethodParamSnowflake(method.Name, index, i, 4, "Spn")
 					}
 					paramsInfo = append(paramsInfo, dto.ParamInfo{
-						Name: name.Name,
-						Type: typeStr, // covering edge-cases like (a,b, c int)
+						Name:     name.Name,
+						Type:     typeStr, // covering edge-cases like (a,b, c int)
+						Callback: tv.handleCallback(p.Type, method, name.Name, index, i),
 					})
 				}
 			}
@@ -237,6 +350,9 @@ func (tv *TypeVisitor) handleParams(params *ast.FieldList, method *dto.Method) e
 		ParamsOverallNames += fmt.Sprintf("%s %s, ", eachParam.Name, eachParam.Type)
 	}
 	method.ParamsNames = paramsNames[:len(paramsNames)-2]
+	if _, variadic := params.List[len(params.List)-1].Type.(*ast.Ellipsis); variadic {
+		method.ParamsNames += "..."
+	}
 	method.ParamsOverallNames = ParamsOverallNames[:len(ParamsOverallNames)-2]
 
 	method.Params = paramsInfo
@@ -261,9 +377,16 @@ func (tv *TypeVisitor) handleResults(results *ast.FieldList, method *dto.Method)
 			if method.HasError && method.ErrorName == "" && typeStr == "error" {
 				method.ErrorName = n
 			}
+			if method.Stream == nil {
+				method.Stream = tv.handleStream(p.Type, n)
+			}
+			if method.Closer == nil {
+				method.Closer = tv.handleCloser(p.Type, n)
+			}
 			resultsInfo = append(resultsInfo, dto.ResultInfo{
 				Name: n,
 				Type: typeStr,
+				Zero: tv.zeroValue(p.Type),
 			})
 		} else {
 			// not really casual :)
@@ -279,9 +402,16 @@ func (tv *TypeVisitor) handleResults(results *ast.FieldList, method *dto.Method)
 				if n == method.SpanName {
 					method.SpanName = MethodParamSnowflake(method.Name, index, i, 4, "Spn")
 				}
+				if method.Stream == nil {
+					method.Stream = tv.handleStream(p.Type, n)
+				}
+				if method.Closer == nil {
+					method.Closer = tv.handleCloser(p.Type, n)
+				}
 				resultsInfo = append(resultsInfo, dto.ResultInfo{
 					Name: n,
 					Type: typeStr,
+					Zero: tv.zeroValue(p.Type),
 				})
 			}
 		}
@@ -302,6 +432,209 @@ func (tv *TypeVisitor) handleResults(results *ast.FieldList, method *dto.Method)
 	return nil
 }
 
+// handleStream reports whether a result type is consumed after the method returns,
+// which is the case for receive channels, iter.Seq, iter.Seq2 and func() (T, bool) cursors
+func (tv *TypeVisitor) handleStream(expr ast.Expr, resultName string) *dto.StreamInfo {
+	switch streamType := expr.(type) {
+	case *ast.ChanType:
+		if streamType.Dir != ast.RECV {
+			return nil
+		}
+		return &dto.StreamInfo{
+			Kind:     dto.StreamChan,
+			Result:   resultName,
+			ElemType: ExprToString(tv.fSet, streamType.Value),
+		}
+	case *ast.IndexExpr:
+		if !tv.isIterType(streamType.X, "Seq") {
+			return nil
+		}
+		return &dto.StreamInfo{
+			Kind:     dto.StreamSeq,
+			Result:   resultName,
+			ElemType: ExprToString(tv.fSet, streamType.Index),
+		}
+	case *ast.IndexListExpr:
+		if !tv.isIterType(streamType.X, "Seq2") || len(streamType.Indices) != 2 {
+			return nil
+		}
+		return &dto.StreamInfo{
+			Kind:     dto.StreamSeq2,
+			Result:   resultName,
+			KeyType:  ExprToString(tv.fSet, streamType.Indices[0]),
+			ElemType: ExprToString(tv.fSet, streamType.Indices[1]),
+		}
+	case *ast.FuncType:
+		if streamType.Params != nil && len(streamType.Params.List) != 0 {
+			return nil
+		}
+		if streamType.Results == nil || len(streamType.Results.List) != 2 {
+			return nil // a cursor has exactly two unnamed results
+		}
+		okType, isIdent := streamType.Results.List[1].Type.(*ast.Ident)
+		if !isIdent || okType.Name != "bool" {
+			return nil
+		}
+		return &dto.StreamInfo{
+			Kind:     dto.StreamCursor,
+			Result:   resultName,
+			ElemType: ExprToString(tv.fSet, streamType.Results.List[0].Type),
+		}
+	}
+	return nil
+}
+
+// handleCallback reports whether a parameter is a function receiving a context as its first
+// argument, which the wrapper replaces with a traced version running as a child span
+func (tv *TypeVisitor) handleCallback(expr ast.Expr, method *dto.Method, paramName string, paramIndex int, nameIndex int) *dto.CallbackInfo {
+	funcType, ok := expr.(*ast.FuncType)
+	if !ok || funcType.Params == nil || len(funcType.Params.List) == 0 {
+		return nil
+	}
+	if !tv.isContext(ExprToString(tv.fSet, funcType.Params.List[0].Type)) {
+		return nil
+	}
+	callback := dto.CallbackInfo{
+		SpanName: fmt.Sprintf("%s.%s", method.SpecialName, paramName),
+		Inner:    MethodParamSnowflake(method.Name, paramIndex, nameIndex, 4, "Cb"),
+		Span:     MethodParamSnowflake(method.Name, paramIndex, nameIndex, 4, "CbSpn"),
+		Late:     MethodParamSnowflake(method.Name, paramIndex, nameIndex, 4, "CbTx"),
+	}
+	var argIndex int
+	for _, field := range funcType.Params.List {
+		typeStr := ExprToString(tv.fSet, field.Type)
+		_, callback.Variadic = field.Type.(*ast.Ellipsis)
+		for range max(len(field.Names), 1) {
+			callback.Params = append(callback.Params, dto.ParamInfo{
+				Name: MethodParamSnowflake(method.Name, paramIndex, argIndex, 4, "CbArg"),
+				Type: typeStr,
+			})
+			argIndex++
+		}
+	}
+	callback.CtxName = callback.Params[0].Name
+	if funcType.Results != nil {
+		var resIndex int
+		for _, field := range funcType.Results.List {
+			typeStr := ExprToString(tv.fSet, field.Type)
+			for range max(len(field.Names), 1) {
+				n := MethodParamSnowflake(method.Name, paramIndex, resIndex, 4, "CbRes")
+				if typeStr == "error" {
+					callback.ErrorName = n
+				}
+				callback.Results = append(callback.Results, dto.ResultInfo{
+					Name: n,
+					Type: typeStr,
+					Zero: tv.zeroValue(field.Type),
+				})
+				resIndex++
+			}
+		}
+	}
+
+	var paramsNames, paramsOverallNames []string
+	for _, eachParam := range callback.Params {
+		paramsNames = append(paramsNames, eachParam.Name)
+		paramsOverallNames = append(paramsOverallNames, fmt.Sprintf("%s %s", eachParam.Name, eachParam.Type))
+	}
+	if callback.Variadic {
+		paramsNames[len(paramsNames)-1] += "..."
+	}
+	var resultNames, resultTypesNames []string
+	for _, eachResult := range callback.Results {
+		resultNames = append(resultNames, eachResult.Name)
+		resultTypesNames = append(resultTypesNames, eachResult.Type)
+	}
+	callback.ParamsNames = strings.Join(paramsNames, ", ")
+	callback.ParamsOverallNames = strings.Join(paramsOverallNames, ", ")
+	callback.ResultNames = strings.Join(resultNames, ", ")
+	callback.ResultTypesNames = strings.Join(resultTypesNames, ", ")
+	return &callback
+}
+
+// handleCloser reports whether a result is a resource the caller closes, either one of
+// the io closers or another interface sirish wraps
+func (tv *TypeVisitor) handleCloser(expr ast.Expr, resultName string) *dto.CloserInfo {
+	switch closerType := expr.(type) {
+	case *ast.SelectorExpr:
+		pkg, ok := closerType.X.(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		if tv.srcAlias != "" && pkg.Name == tv.srcAlias {
+			return tv.handleTargetCloser(closerType.Sel, resultName) // qualified for the output package
+		}
+		if alias, ok := tv.importAlias["io"]; !ok || alias != pkg.Name {
+			return nil
+		}
+		switch kind := dto.CloserKind(closerType.Sel.Name); kind {
+		case dto.CloserPlain, dto.CloserReader, dto.CloserWriter, dto.CloserReadWriter:
+			return &dto.CloserInfo{
+				Kind:   kind,
+				Result: resultName,
+				Type:   ExprToString(tv.fSet, closerType),
+				Closes: true,
+			}
+		}
+	case *ast.Ident:
+		return tv.handleTargetCloser(closerType, resultName)
+	}
+	return nil
+}
+
+func (tv *TypeVisitor) handleTargetCloser(name *ast.Ident, resultName string) *dto.CloserInfo {
+	if !tv.targetInterfaces.Exists(name.Name) || tv.funcs.has(name.Name) {
+		return nil
+	}
+	return &dto.CloserInfo{
+		Kind:   dto.CloserTarget,
+		Result: resultName,
+		Type:   name.Name,
+	}
+}
+
+// resolveTargetClosers keeps the target closers which are wrapped in this file and marks
+// the ones whose Close() ends the span. targets may be declared after their usage
+func (tv *TypeVisitor) resolveTargetClosers() {
+	closable := make(map[string]bool, len(tv.wrappedInterfaces))
+	for _, eachInterface := range tv.wrappedInterfaces {
+		closable[eachInterface.Name] = eachInterface.Closable
+	}
+	for i := range tv.wrappedInterfaces {
+		methods := tv.wrappedInterfaces[i].Methods
+		for j := range methods {
+			if methods[j].Closer == nil || methods[j].Closer.Kind != dto.CloserTarget {
+				continue
+			}
+			closes, ok := closable[methods[j].Closer.Type]
+			if !ok {
+				methods[j].Closer = nil
+				continue
+			}
+			methods[j].Closer.Closes = closes
+		}
+	}
+}
+
+func isCloseMethod(method dto.Method) bool {
+	return method.Name == "Close" && len(method.Params) == 0 &&
+		len(method.Results) == 1 && method.Results[0].Type == "error"
+}
+
+// isIterType checks expr is the given type of the standard iter package, whatever its alias is
+func (tv *TypeVisitor) isIterType(expr ast.Expr, name string) bool {
+	selector, ok := expr.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != name {
+		return false
+	}
+	pkg, ok := selector.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	alias, ok := tv.importAlias["iter"]
+	return ok && alias == pkg.Name
+}
+
 func (tv *TypeVisitor) handleImports(node *ast.ImportSpec) error {
 	unquoteImport, err := strconv.Unquote(node.Path.Value)
 	if err != nil {
@@ -311,7 +644,12 @@ func (tv *TypeVisitor) handleImports(node *ast.ImportSpec) error {
 	if node.Name != nil {
 		alias = node.Name.Name
 	} else {
-		alias = path.Base(unquoteImport)
+		alias = importName(unquoteImport)
+		for name, importPath := range tv.qualifiers {
+			if importPath == unquoteImport {
+				alias = name // the guess was fixed by the names the targets use
+			}
+		}
 	}
 	tv.importAlias[unquoteImport] = alias
 	return nil
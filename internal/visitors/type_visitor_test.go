@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -252,7 +253,8 @@ func TestImportsWorks(t *testing.T) {
 		interfaces []string
 	}
 	type result struct {
-		expectedImports dto.PkgImports
+		expectedImports     dto.PkgImports
+		expectedUsedImports dto.PkgImports
 	}
 	type scenario struct {
 		name   string
@@ -271,6 +273,58 @@ func TestImportsWorks(t *testing.T) {
 					"math/rand": "rand2",
 					"github.com/pm1381/sirish/internal/visitors/test_samples/rand": "rand",
 				},
+				expectedUsedImports: map[string]string{
+					"math/rand": "rand2",
+					"github.com/pm1381/sirish/internal/visitors/test_samples/rand": "rand",
+				},
+			},
+		},
+		{
+			name: "UnusedImportsTest",
+			input: input{
+				filename:   "imports.go",
+				interfaces: []string{"OnlyRand"},
+			},
+			result: result{
+				expectedImports: map[string]string{
+					"math/rand": "rand2",
+					"github.com/pm1381/sirish/internal/visitors/test_samples/rand": "rand",
+				},
+				expectedUsedImports: map[string]string{
+					"github.com/pm1381/sirish/internal/visitors/test_samples/rand": "rand",
+				},
+			},
+		},
+		{
+			name: "PackageNameDiffersFromPathTest",
+			input: input{
+				filename:   "guess.go",
+				interfaces: []string{"Guessed"},
+			},
+			result: result{
+				expectedImports: map[string]string{
+					"github.com/pm1381/sirish/internal/visitors/test_samples/randutil": "random",
+				},
+				expectedUsedImports: map[string]string{
+					"github.com/pm1381/sirish/internal/visitors/test_samples/randutil": "random",
+				},
+			},
+		},
+		{
+			name: "PackageNamesDifferFromPathsTest",
+			input: input{
+				filename:   "guess_many.go",
+				interfaces: []string{"GuessedMany"},
+			},
+			result: result{
+				expectedImports: map[string]string{
+					"github.com/pm1381/sirish/internal/visitors/test_samples/randutil": "random",
+					"github.com/pm1381/sirish/internal/visitors/test_samples/wordutil": "words",
+				},
+				expectedUsedImports: map[string]string{
+					"github.com/pm1381/sirish/internal/visitors/test_samples/randutil": "random",
+					"github.com/pm1381/sirish/internal/visitors/test_samples/wordutil": "words",
+				},
 			},
 		},
 	}
@@ -288,6 +342,185 @@ func TestImportsWorks(t *testing.T) {
 
 			// assert
 			assert.True(t, reflect.DeepEqual(each.result.expectedImports, wrapper.GetImports()))
+			interfaces := wrapper.GetWrappedInterfaces()
+			require.Len(t, interfaces, 1)
+			assert.Equal(t, each.result.expectedUsedImports, interfaces[0].Imports)
+		})
+	}
+}
+
+func TestStreamResultsWorks(t *testing.T) {
+	type scenario struct {
+		name     string
+		method   string
+		expected *dto.StreamInfo
+	}
+	scenarios := []scenario{
+		{
+			name:     "ReceiveChannel",
+			method:   "Method1",
+			expected: &dto.StreamInfo{Kind: dto.StreamChan, ElemType: "string"},
+		},
+		{
+			name:     "IterSeq",
+			method:   "Method2",
+			expected: &dto.StreamInfo{Kind: dto.StreamSeq, ElemType: "*int"},
+		},
+		{
+			name:     "IterSeq2",
+			method:   "Method3",
+			expected: &dto.StreamInfo{Kind: dto.StreamSeq2, Result: "all", KeyType: "string", ElemType: "[]byte"},
+		},
+		{
+			name:     "Cursor",
+			method:   "Method4",
+			expected: &dto.StreamInfo{Kind: dto.StreamCursor, ElemType: "string"},
+		},
+		{
+			name:     "NotStreams",
+			method:   "Method5",
+			expected: nil,
+		},
+	}
+
+	abs := internal.GetTestPathHelper("types.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"StreamResults"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			var method *dto.Method
+			for i := range interfaces[0].Methods {
+				if interfaces[0].Methods[i].Name == s.method {
+					method = &interfaces[0].Methods[i]
+				}
+			}
+			require.NotNil(t, method)
+			if s.expected == nil {
+				assert.Nil(t, method.Stream)
+				return
+			}
+			require.NotNil(t, method.Stream)
+			assert.Equal(t, s.expected.Kind, method.Stream.Kind)
+			assert.Equal(t, s.expected.KeyType, method.Stream.KeyType)
+			assert.Equal(t, s.expected.ElemType, method.Stream.ElemType)
+			if s.expected.Result != "" {
+				assert.Equal(t, s.expected.Result, method.Stream.Result)
+			}
+			assert.Equal(t, method.Results[0].Name, method.Stream.Result)
 		})
 	}
 }
+
+func TestCloserResultsWorks(t *testing.T) {
+	type scenario struct {
+		name     string
+		method   string
+		expected *dto.CloserInfo
+	}
+	scenarios := []scenario{
+		{
+			name:     "ReadCloser",
+			method:   "Method1",
+			expected: &dto.CloserInfo{Kind: dto.CloserReader, Type: "io.ReadCloser", Closes: true},
+		},
+		{
+			name:     "SecondResult",
+			method:   "Method2",
+			expected: &dto.CloserInfo{Kind: dto.CloserWriter, Result: "c", Type: "io.WriteCloser", Closes: true},
+		},
+		{
+			name:     "ClosableTarget",
+			method:   "Method3",
+			expected: &dto.CloserInfo{Kind: dto.CloserTarget, Type: "Closable", Closes: true},
+		},
+		{
+			name:     "Target",
+			method:   "Method4",
+			expected: &dto.CloserInfo{Kind: dto.CloserTarget, Type: "NoResult", Closes: false},
+		},
+		{
+			name:     "NotATarget",
+			method:   "Method5",
+			expected: nil,
+		},
+	}
+
+	abs := internal.GetTestPathHelper("types.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"CloserResults", "Closable", "NoResult"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	var closerResults dto.InterfaceInfo
+	for _, eachInterface := range typeVisitor.GetWrappedInterfaces() {
+		switch eachInterface.Name {
+		case "CloserResults":
+			closerResults = eachInterface
+		case "Closable":
+			assert.True(t, eachInterface.Closable)
+		default:
+			assert.False(t, eachInterface.Closable)
+		}
+	}
+	require.Equal(t, "CloserResults", closerResults.Name)
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			var method *dto.Method
+			for i := range closerResults.Methods {
+				if closerResults.Methods[i].Name == s.method {
+					method = &closerResults.Methods[i]
+				}
+			}
+			require.NotNil(t, method)
+			if s.expected == nil {
+				assert.Nil(t, method.Closer)
+				return
+			}
+			require.NotNil(t, method.Closer)
+			assert.Equal(t, s.expected.Kind, method.Closer.Kind)
+			assert.Equal(t, s.expected.Type, method.Closer.Type)
+			assert.Equal(t, s.expected.Closes, method.Closer.Closes)
+			if s.expected.Result != "" {
+				assert.Equal(t, s.expected.Result, method.Closer.Result)
+			}
+		})
+	}
+}
+
+func TestCallbackParamsWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("types.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"CallbackParams"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 1)
+	methods := interfaces[0].Methods
+	require.Len(t, methods, 3)
+
+	// Method1(ctx context.Context, fn func(ctx context.Context, s string) error) error
+	assert.Nil(t, methods[0].Params[0].Callback)
+	callback := methods[0].Params[1].Callback
+	require.NotNil(t, callback)
+	assert.Equal(t, "CallbackParams.Method1.fn", callback.SpanName)
+	assert.Len(t, callback.Params, 2)
+	assert.Equal(t, callback.Params[0].Name, callback.CtxName)
+	assert.Equal(t, callback.Results[0].Name, callback.ErrorName)
+	assert.False(t, callback.Variadic)
+
+	// Method2(fn func(context.Context, ...int) (int, bool))
+	callback = methods[1].Params[0].Callback
+	require.NotNil(t, callback)
+	assert.True(t, callback.Variadic)
+	assert.Equal(t, "", callback.ErrorName)
+	assert.Equal(t, "int, bool", callback.ResultTypesNames)
+	assert.True(t, strings.HasSuffix(callback.ParamsNames, "..."))
+
+	// Method3(fn func(s string) error, _ func(context.Context))
+	assert.Nil(t, methods[2].Params[0].Callback)
+	require.NotNil(t, methods[2].Params[1].Callback)
+	assert.Equal(t, "CallbackParams.Method3.callback1", methods[2].Params[1].Callback.SpanName)
+}
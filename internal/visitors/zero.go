@@ -0,0 +1,121 @@
+package visitors
+
+import (
+	"go/ast"
+	"go/parser"
+	"strings"
+)
+
+// ZeroValue returns an expression for the zero value of the printable type typeExpr. types it cannot
+// tell apart, like named types of other packages or type params, get *new(T) which fits all of them
+func ZeroValue(typeExpr string) string {
+	expr, err := parser.ParseExpr(typeExpr)
+	if err != nil {
+		return "*new(" + typeExpr + ")"
+	}
+	switch node := expr.(type) {
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return "nil"
+	case *ast.ArrayType:
+		if node.Len == nil {
+			return "nil" // slice
+		}
+		return typeExpr + "{}"
+	case *ast.StructType:
+		return typeExpr + "{}"
+	case *ast.Ident:
+		if zero, ok := builtinZeros[node.Name]; ok {
+			return zero
+		}
+	}
+	return "*new(" + typeExpr + ")"
+}
+
+var builtinZeros = map[string]string{
+	"bool": "false", "string": `""`, "error": "nil", "any": "nil",
+	"int": "0", "int8": "0", "int16": "0", "int32": "0", "int64": "0",
+	"uint": "0", "uint8": "0", "uint16": "0", "uint32": "0", "uint64": "0", "uintptr": "0",
+	"float32": "0", "float64": "0", "complex64": "0", "complex128": "0", "byte": "0", "rune": "0",
+}
+
+// zeroValue is ZeroValue knowing the types the package declares and the type params in scope
+func (tv *TypeVisitor) zeroValue(expr ast.Expr) string {
+	typeStr := ExprToString(tv.fSet, expr)
+	if zero := tv.declaredZero(typeStr, expr, 0); zero != "" {
+		return zero
+	}
+	return ZeroValue(typeStr)
+}
+
+// declaredZero resolves the zero value of the types declared in the package, following their
+// definitions. it returns an empty string for the ones ZeroValue handles on its own
+func (tv *TypeVisitor) declaredZero(typeStr string, expr ast.Expr, depth int) string {
+	var name string
+	switch node := expr.(type) {
+	case *ast.ParenExpr:
+		return tv.declaredZero(typeStr, node.X, depth)
+	case *ast.Ident:
+		if tv.typeParams.has(node.Name) {
+			return "*new(" + typeStr + ")"
+		}
+		if _, ok := builtinZeros[node.Name]; ok {
+			return ""
+		}
+		name = node.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := node.X.(*ast.Ident); !ok || tv.srcAlias == "" || pkg.Name != tv.srcAlias {
+			return "" // declared by another package
+		}
+		name = node.Sel.Name
+	case *ast.IndexExpr:
+		if tv.declaredZero(typeStr, node.X, depth) == typeStr+"{}" {
+			return typeStr + "{}" // instantiated generic struct
+		}
+		return ""
+	case *ast.IndexListExpr:
+		if tv.declaredZero(typeStr, node.X, depth) == typeStr+"{}" {
+			return typeStr + "{}"
+		}
+		return ""
+	default:
+		return ""
+	}
+
+	spec, ok := tv.fileSpecs[name]
+	if !ok {
+		_, typeSpecs, err := tv.packageDecls()
+		if err != nil {
+			return "" // returned by TraverseFile
+		}
+		spec, ok = typeSpecs[name]
+	}
+	if !ok || depth > 10 {
+		return ""
+	}
+	definition := spec.Type
+	for paren, ok := definition.(*ast.ParenExpr); ok; paren, ok = definition.(*ast.ParenExpr) {
+		definition = paren.X
+	}
+	switch underlying := definition.(type) {
+	case *ast.StructType:
+		return typeStr + "{}"
+	case *ast.ArrayType:
+		if underlying.Len != nil {
+			return typeStr + "{}"
+		}
+		return "nil"
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return "nil"
+	case *ast.Ident:
+		if zero, ok := builtinZeros[underlying.Name]; ok {
+			return zero // untyped constants and nil convert to the named type
+		}
+		if zero := tv.declaredZero(underlying.Name, underlying, depth+1); zero != "" && zero[0] != '*' {
+			if strings.HasSuffix(zero, "{}") {
+				return typeStr + "{}"
+			}
+			return zero
+		}
+	}
+	return "*new(" + typeStr + ")"
+}
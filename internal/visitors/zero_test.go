@@ -0,0 +1,76 @@
+package visitors
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestZeroValueWorks(t *testing.T) {
+	scenarios := map[string]string{
+		"error":              "nil",
+		"*User":              "nil",
+		"[]byte":             "nil",
+		"map[string]int":     "nil",
+		"<-chan int":         "nil",
+		"func() error":       "nil",
+		"interface{}":        "nil",
+		"any":                "nil",
+		"string":             `""`,
+		"bool":               "false",
+		"int64":              "0",
+		"rune":               "0",
+		"[4]int":             "[4]int{}",
+		"struct{ ID int }":   "struct{ ID int }{}",
+		"User":               "*new(User)",
+		"time.Time":          "*new(time.Time)",
+		"T":                  "*new(T)",
+		"iter.Seq[string]":   "*new(iter.Seq[string])",
+		"map[string]struct{": "*new(map[string]struct{)",
+	}
+	for typeExpr, want := range scenarios {
+		assert.Equal(t, want, ZeroValue(typeExpr), typeExpr)
+	}
+}
+
+func TestResultZeroValuesWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("zero.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Zeros", "GenericZeros"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	require.Len(t, interfaces, 2)
+
+	zeros := func(results []dto.ResultInfo) []string {
+		var res []string
+		for _, result := range results {
+			res = append(res, result.Zero)
+		}
+		return res
+	}
+	methods := interfaces[0].Methods
+	assert.Equal(t, []string{"0", `""`, "false", "nil"}, zeros(methods[0].Results))
+	assert.Equal(t, []string{"0", `""`, "false", "0"}, zeros(methods[1].Results))
+	assert.Equal(t, []string{"ZeroPoint{}", "ZeroGrid{}", "nil", "nil", "nil", "nil"}, zeros(methods[2].Results))
+	assert.Equal(t, []string{"ZeroAlias{}", "ZeroLocation{}", "nil", "ZeroPage[ZeroPoint]{}", "nil"}, zeros(methods[3].Results))
+	assert.Equal(t, []string{"*new(time.Time)", "*new(context.Context)", "[3]byte{}"}, zeros(methods[4].Results))
+	assert.Equal(t, []string{"0", "nil"}, zeros(methods[5].Params[1].Callback.Results))
+
+	// type params can be anything
+	assert.Equal(t, []string{"*new(T)", "nil", "nil"}, zeros(interfaces[1].Methods[0].Results))
+}
+
+func TestResultZeroValuesOutputPackageWorks(t *testing.T) {
+	abs := internal.GetTestPathHelper("zero.go", "visitors")
+	typeVisitor := NewTypeVisitor(abs, dto.Types{"Zeros"})
+	typeVisitor.SetOutput("tracing", "")
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+	methods := typeVisitor.GetWrappedInterfaces()[0].Methods
+
+	assert.Equal(t, "0", methods[1].Results[0].Zero)
+	assert.Equal(t, "test_samples.ZeroPoint{}", methods[2].Results[0].Zero)
+	assert.Equal(t, "test_samples.ZeroPage[test_samples.ZeroPoint]{}", methods[3].Results[3].Zero)
+}
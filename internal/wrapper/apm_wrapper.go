@@ -2,11 +2,13 @@ package wrapper
 
 import (
 	"bytes"
-	"embed"
 	"errors"
 	"fmt"
+	"github.com/pm1381/sirish/internal"
 	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/visitors"
 	"golang.org/x/tools/imports"
+	"io/fs"
 	"os"
 	"path"
 	"strings"
@@ -16,91 +18,209 @@ import (
 type apmWrapper struct {
 	suffix     string
 	template   *template.Template
+	digest     string // TemplateDigest of template
 	interfaces []dto.InterfaceInfo
-	imports    dto.PkgImports
 }
 
-type generatorValues struct {
-	Version   string
-	Interface dto.InterfaceInfo
-	Imports   dto.PkgImports
-	Suffix    string
-	TypeName  string
-	CreateTx  bool
-}
+const APMPath = visitors.APMPath
 
-const APMPath = "go.elastic.co/apm/v2"
+func NewApmWrapper(suffix string, pattern string, f fs.FS, interfaces []dto.InterfaceInfo) WrapperInterface {
+	if pattern == "" {
+		pattern = EntryTemplate
+	}
+	return NewApmWrapperWithTemplate(suffix, template.Must(ParseTemplate(f, pattern)), interfaces)
+}
 
-func NewApmWrapper(suffix string, pattern string, f embed.FS, interfaces []dto.InterfaceInfo, imports dto.PkgImports) WrapperInterface {
+// NewApmWrapperWithTemplate generates the wrappers with an already parsed template, like the ones LoadTemplate reads
+func NewApmWrapperWithTemplate(suffix string, tmpl *template.Template, interfaces []dto.InterfaceInfo) WrapperInterface {
 	if suffix == "" {
 		suffix = "sirish"
 	}
-	if pattern == "" {
-		pattern = "internal/templates/wrapper.gotmpl"
-	}
-	if !imports.PathExists(APMPath) {
-		imports[APMPath] = "apm" // add APM paths
-	}
 	tw := &apmWrapper{
 		suffix:     suffix,
 		interfaces: interfaces,
-		imports:    imports,
-		template:   template.Must(template.ParseFS(f, pattern)),
+		template:   tmpl,
+		digest:     TemplateDigest(tmpl),
 	}
 	return tw
 }
 
 func (tw *apmWrapper) Generate(opts Options) error {
-	options, ok := opts.(APMTypeWrapperOptions)
+	return tw.GenerateTo(opts, NewDiskSink())
+}
+
+func (tw *apmWrapper) GenerateTo(opts Options, sink Sink) error {
+	files, err := tw.Render(opts)
+	if err != nil {
+		sink.Close()
+		return err
+	}
+	return WriteFiles(sink, files)
+}
+
+// Hashes returns the path and source hash of every wrapper Render returns, without executing the template
+func (tw *apmWrapper) Hashes(opts Options) ([]FileHash, error) {
+	wrapperOptions, ok := opts.(APMTypeWrapperOptions)
 	if !ok {
-		return errors.New("invalid options")
+		return nil, errors.New("invalid options")
 	}
+	hashes := make([]FileHash, 0, len(tw.interfaces))
 	for _, eachInterface := range tw.interfaces {
-		fmt.Printf("- generating sirish for interface %s in directory %s \n", eachInterface.Name, eachInterface.Directory)
-
-		var err error
-		buf := new(bytes.Buffer)
-		filenameSuffix := fmt.Sprintf("%s.%s.go", strings.ReplaceAll(eachInterface.FileName, ".go", ""), tw.suffix) // profile_store.go ---> profile_store.sirish.go  OR interfaceName.profile_store.go ---> interfaceName.profile_store.sirish.go
-		fullPath := path.Join(eachInterface.Directory, filenameSuffix)
-		var processed []byte
-
-		if err = tw.template.ExecuteTemplate(buf, "wrapper.gotmpl", generatorValues{
-			Version:   options.Version,
-			Interface: eachInterface,
-			Imports:   tw.imports,
-			Suffix:    tw.suffix,
-			CreateTx:  options.CreateTx,
-			TypeName:  eachInterface.Name + strings.Replace(tw.suffix, string(tw.suffix[0]), strings.ToUpper(string(tw.suffix[0])), 1),
-		}); err != nil {
-			fmt.Printf("error occured on execution: %v", err)
-			continue
+		hash, err := SourceHash(eachInterface, wrapperOptions.forInterface(eachInterface.Name), tw.suffix, tw.digest, wrapperOptions.Directive)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, FileHash{Path: OutputPath(eachInterface, tw.suffix), Interface: eachInterface.Name, Hash: hash})
+	}
+	return hashes, nil
+}
+
+// Render executes the template for every interface on opts.Jobs workers without writing anything, the files
+// keep the order of the interfaces. with Incremental set, the wrappers already carrying their source hash
+// are read from disk instead
+func (tw *apmWrapper) Render(opts Options) ([]File, error) {
+	wrapperOptions, ok := opts.(APMTypeWrapperOptions)
+	if !ok {
+		return nil, errors.New("invalid options")
+	}
+	files, err := internal.ParallelMap(wrapperOptions.Jobs, tw.interfaces, func(eachInterface dto.InterfaceInfo) (File, error) {
+		return tw.render(eachInterface, wrapperOptions)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// render executes the template for one interface and formats the result
+func (tw *apmWrapper) render(eachInterface dto.InterfaceInfo, wrapperOptions APMTypeWrapperOptions) (File, error) {
+	options := APMTypeWrapperOptions{GeneralOptions: wrapperOptions.forInterface(eachInterface.Name)}
+
+	buf := new(bytes.Buffer)
+	fullPath := OutputPath(eachInterface, tw.suffix)
+	var processed []byte
+
+	hash, err := SourceHash(eachInterface, options.GeneralOptions, tw.suffix, tw.digest, wrapperOptions.Directive)
+	if err != nil {
+		return File{}, err
+	}
+	if wrapperOptions.Incremental {
+		existing, err := ReadHash(fullPath)
+		if err != nil {
+			return File{}, err
 		}
-		if options.Imports {
-			processed, err = tw.formatImports(fullPath, buf)
+		if existing == hash {
+			content, err := os.ReadFile(fullPath)
 			if err != nil {
-				fmt.Printf("error formatting imports: %v", err)
-				processed = buf.Bytes()
+				return File{}, err
 			}
-		} else {
-			processed = buf.Bytes()
+			return File{Path: fullPath, Content: content, Interface: eachInterface.Name}, nil
 		}
-		f, err := os.Create(fullPath)
+	}
+
+	typeSuffix := upperFirst(tw.suffix)
+	closerKinds := tw.closerKinds(eachInterface, options)
+	if err = tw.template.Execute(buf, TemplateData{
+		DataVersion:  TemplateDataVersion,
+		Version:      options.Version,
+		Interface:    eachInterface,
+		Imports:      tw.interfaceImports(eachInterface, options, closerKinds),
+		Suffix:       tw.suffix,
+		CreateTx:     options.CreateTx,
+		Streams:      options.Streams,
+		Closers:      options.Closers,
+		Callbacks:    options.Callbacks,
+		CloserKinds:  closerKinds,
+		SpanType:     options.SpanType,
+		Labels:       options.Labels,
+		TypeName:     eachInterface.Name + typeSuffix,
+		TypeSuffix:   typeSuffix,
+		HelperPrefix: lowerFirst(eachInterface.Name) + typeSuffix,
+	}); err != nil {
+		return File{}, fmt.Errorf("generating wrapper of %s: %w", eachInterface.Name, err) // names the template line
+	}
+	if options.Imports {
+		processed, err = formatImports(fullPath, buf.Bytes())
 		if err != nil {
-			fmt.Printf("error creating file: %v", err)
-			continue
+			return File{}, fmt.Errorf("formatting wrapper of %s: %w", eachInterface.Name, err)
 		}
-		if _, err := f.Write(processed); err != nil {
-			fmt.Printf("error writing to file: %v", err)
-			f.Close()
+	} else {
+		processed = buf.Bytes()
+	}
+	content := stampHeader(processed, HashPrefix+hash, sourceLine(fullPath, wrapperOptions.Source), directiveLine(wrapperOptions.Directive))
+	return File{Path: fullPath, Content: content, Interface: eachInterface.Name}, nil
+}
+
+// OutputPath is the file the wrapper of info is written to,
+// profile_store.go ---> profile_store.sirish.go OR interfaceName.profile_store.go ---> interfaceName.profile_store.sirish.go
+func OutputPath(info dto.InterfaceInfo, suffix string) string {
+	if suffix == "" {
+		suffix = "sirish"
+	}
+	return path.Join(info.OutDirectory, fmt.Sprintf("%s.%s.go", strings.ReplaceAll(info.FileName, ".go", ""), suffix))
+}
+
+// interfaceImports adds the packages the wrapper body of info refers to on top of the ones its
+// signatures use, named the way the visitor picked for them
+func (tw *apmWrapper) interfaceImports(info dto.InterfaceInfo, options APMTypeWrapperOptions, closerKinds map[dto.CloserKind]string) dto.PkgImports {
+	extra := map[string]string{
+		APMPath: info.Pkgs.Apm,
+	}
+	if len(closerKinds) != 0 {
+		extra[visitors.SyncPath] = info.Pkgs.Sync
+		extra[visitors.AtomicPath] = info.Pkgs.Atomic
+	}
+	for _, method := range info.Methods {
+		if options.Streams && method.Stream != nil && (method.HasCtx || options.CreateTx) {
+			extra[visitors.SyncPath] = info.Pkgs.Sync
+		}
+		if options.CreateTx && !method.HasCtx {
+			extra[visitors.ContextPath] = info.Pkgs.Context
+		}
+		if options.Callbacks && method.HasCallback() && (method.HasCtx || options.CreateTx) {
+			extra[visitors.AtomicPath] = info.Pkgs.Atomic
+		}
+	}
+	res := make(dto.PkgImports, len(info.Imports)+len(extra))
+	for importPath, alias := range info.Imports {
+		res[importPath] = alias
+	}
+	for importPath, alias := range extra {
+		if alias == "" {
+			alias = path.Base(importPath)
+		}
+		if !res.PathExists(importPath) {
+			res[importPath] = alias
+		}
+	}
+	return res
+}
+
+// closerKinds lists the io closers which need an adapter type in the wrapper of info, an entry
+// for targets means the lifetime helper is needed by a target wrapper which ends on close
+func (tw *apmWrapper) closerKinds(info dto.InterfaceInfo, options APMTypeWrapperOptions) map[dto.CloserKind]string {
+	res := make(map[dto.CloserKind]string)
+	if !options.Closers {
+		return res
+	}
+	for _, method := range info.Methods {
+		if method.Closer == nil || !(method.HasCtx || options.CreateTx) {
 			continue
 		}
-		f.Close()
+		if options.Streams && method.Stream != nil {
+			continue // the stream ends the span
+		}
+		if method.Closer.Kind != dto.CloserTarget {
+			res[method.Closer.Kind] = method.Closer.Type
+		} else if method.Closer.Closes {
+			res[dto.CloserTarget] = "" // only the lifetime helper is needed
+		}
 	}
-	return nil
+	return res
 }
 
-func (tw *apmWrapper) formatImports(fileAbsPath string, buffer *bytes.Buffer) ([]byte, error) {
-	formatedFile, err := imports.Process(fileAbsPath, buffer.Bytes(), nil)
+func formatImports(fileAbsPath string, src []byte) ([]byte, error) {
+	formatedFile, err := imports.Process(fileAbsPath, src, nil)
 	if err != nil {
 		return nil, err
 	}
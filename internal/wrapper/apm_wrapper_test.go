@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"github.com/pm1381/sirish/internal"
 	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/templates"
 	"github.com/pm1381/sirish/internal/visitors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"os"
+	"golang.org/x/tools/go/packages"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -63,66 +65,796 @@ func TestAPMWrapperGenerator(t *testing.T) {
 			err := typeVisitor.Traverse()
 			require.NoError(t, err)
 
-			apmW := NewApmWrapper("sirish", "test_samples/template/wrapper.gotmpl", f, typeVisitor.GetWrappedInterfaces(), typeVisitor.GetImports())
-			err = apmW.Generate(APMTypeWrapperOptions{
-				GeneralOptions{
+			apmW := NewApmWrapper("sirish", "test_samples/template/wrapper.gotmpl", f, typeVisitor.GetWrappedInterfaces())
+			module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+				GeneralOptions: GeneralOptions{
 					Version:  "0.0.1",
 					Imports:  true,
 					CreateTx: true,
 				},
 			})
-			require.NoError(t, err)
-			sirishFiles := getFileNamesInDir(t, "test_samples")
+			var correctFileNames []string
 			if len(s.input.interfaces) > 1 {
 				// the naming structure differs
-				var correctFileNames []string
 				for _, eachIntr := range s.input.interfaces {
 					correctFileNames = append(correctFileNames, fmt.Sprintf("%s.%s.sirish.go", eachIntr, strings.ReplaceAll(s.input.filename, ".go", "")))
 				}
-				fmt.Println(correctFileNames, sirishFiles)
-				var foundCount int
-				for _, wantName := range correctFileNames {
-					for _, existFile := range sirishFiles {
-						if wantName == existFile {
-							foundCount++
-						}
-					}
-				}
-				assert.Equal(t, len(correctFileNames), foundCount)
 			} else {
-				var found bool
-				generatedFile := fmt.Sprintf("%s.%s.go", strings.ReplaceAll(s.input.filename, ".go", ""), "sirish")
-				for _, file := range sirishFiles {
-					if file == generatedFile {
-						found = true
-					}
-				}
-				assert.True(t, found)
+				correctFileNames = append(correctFileNames, fmt.Sprintf("%s.%s.go", strings.ReplaceAll(s.input.filename, ".go", ""), "sirish"))
+			}
+			for _, wantName := range correctFileNames {
+				assert.Contains(t, files, wantName)
 			}
+			assertCompiles(t, filepath.Join(module, samplesPath))
 		})
 	}
-	// removing generated files
-	genFiles := getFileNamesInDir(t, "test_samples")
-	for _, file := range genFiles {
-		if strings.HasSuffix(file, ".sirish.go") {
-			fmt.Println(file)
-			// TODO: optional Remove
-			//path := internal.GetTestPathHelper(file, "")
-			//err := os.Remove(path)
-			//require.NoError(t, err)
+}
+
+func TestAPMWrapperStreams(t *testing.T) {
+	path := internal.GetTestPathHelper("stream_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Streams"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:  "0.0.1",
+			Imports:  true,
+			CreateTx: true,
+			Streams:  true,
+		},
+	})
+
+	generated := files["stream_samples.sirish.go"]
+	assert.Equal(t, 5, strings.Count(generated, "streamEnd := func(items int, exhausted bool)"))
+	assert.Contains(t, generated, "var _ Streams = (*StreamsSirishWrapperImpl)(nil)")
+	assert.Contains(t, generated, "func (w *StreamsSirishWrapperImpl) Unwrap() Streams {")
+	assert.Contains(t, generated, "if existing, ok := wrapped.(*StreamsSirishWrapperImpl); ok {")
+	assert.NotContains(t, generated, "defer span.End()")
+	assertCompiles(t, filepath.Join(module, samplesPath))
+	runSamples(t, module, streamsRuntime)
+}
+
+// streamsRuntime checks the spans of the stream adapters against a recording tracer
+const streamsRuntime = `package test_samples
+
+import (
+	"context"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.elastic.co/apm/v2"
+	"go.elastic.co/apm/v2/apmtest"
+)
+
+type streams struct {
+	drained chan struct{}
+}
+
+func (s streams) Watch(ctx context.Context, key string) (<-chan string, error) {
+	out := make(chan string)
+	go func() {
+		defer close(s.drained)
+		defer close(out)
+		for _, item := range []string{"a", "b", "c", "d"} {
+			out <- item
+		}
+	}()
+	return out, nil
+}
+
+func (s streams) All(ctx context.Context) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := range 5 {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func (s streams) Pairs() iter.Seq2[string, int] { return nil }
+
+func (s streams) Next(ctx context.Context) (func() ([]byte, bool), error) { return nil, nil }
+
+func (s streams) Feed(n int) <-chan int {
+	out := make(chan int, n)
+	for i := range n {
+		out <- i
+	}
+	close(out)
+	return out
+}
+
+func traced(t *testing.T) (*StreamsSirishWrapperImpl, *apmtest.RecordingTracer, context.Context, streams) {
+	tracer := apmtest.NewRecordingTracer()
+	t.Cleanup(tracer.Close)
+	tx := tracer.StartTransaction("test", "test")
+	t.Cleanup(tx.End)
+	impl := streams{drained: make(chan struct{})}
+	return NewStreamsSirishWrapperImpl("streams", impl, "test"), tracer, apm.ContextWithTransaction(context.Background(), tx), impl
+}
+
+func TestChannelDrained(t *testing.T) {
+	w, tracer, ctx, _ := traced(t)
+	items, err := w.Watch(ctx, "key")
+	require.NoError(t, err)
+	var got []string
+	for item := range items {
+		got = append(got, item)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, got)
+	span := endedSpans(t, tracer, 1)[0]
+	assert.Equal(t, "Streams.Watch", span.Name)
+	assert.EqualValues(t, 4, label(span, "stream_items"))
+	assert.Equal(t, true, label(span, "stream_exhausted"))
+}
+
+func TestChannelCancelled(t *testing.T) {
+	w, tracer, ctx, impl := traced(t)
+	ctx, cancel := context.WithCancel(ctx)
+	items, err := w.Watch(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "a", <-items)
+	cancel()
+	select {
+	case <-impl.drained: // the producer is not blocked by the consumer which left
+	case <-time.After(5 * time.Second):
+		t.Fatal("the producer is still blocked")
+	}
+	for range items {
+	}
+	span := endedSpans(t, tracer, 1)[0]
+	assert.Equal(t, false, label(span, "stream_exhausted"))
+}
+
+func TestChannelKeepsBuffer(t *testing.T) {
+	tracer := apmtest.NewRecordingTracer()
+	defer tracer.Close()
+	apm.SetDefaultTracer(tracer.Tracer)
+	items := NewStreamsSirishWrapperImpl("streams", streams{}, "test").Feed(3)
+	assert.Equal(t, 3, cap(items))
+	var count int
+	for range items {
+		count++
+	}
+	assert.Equal(t, 3, count)
+	span := endedSpans(t, tracer, 1)[0]
+	assert.EqualValues(t, 3, label(span, "stream_items"))
+	tracer.Flush(nil)
+	assert.Len(t, tracer.Payloads().Transactions, 1, "the transaction of a method without a context ends with its span")
+}
+
+func TestIteratorStopped(t *testing.T) {
+	w, tracer, ctx, _ := traced(t)
+	var got []int
+	for item := range w.All(ctx) {
+		if item == 2 {
+			break
 		}
+		got = append(got, item)
 	}
+	assert.Equal(t, []int{0, 1}, got)
+	span := endedSpans(t, tracer, 1)[0]
+	assert.EqualValues(t, 3, label(span, "stream_items"))
+	assert.Equal(t, false, label(span, "stream_exhausted"))
 }
+`
 
-func getFileNamesInDir(t *testing.T, dir string) (res []string) {
-	files, err := os.ReadDir(dir)
+func TestAPMWrapperClosers(t *testing.T) {
+	path := internal.GetTestPathHelper("resource_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Storage", "Tx", "Session"})
+	err := typeVisitor.Traverse()
 	require.NoError(t, err)
-	for _, file := range files {
-		if !file.IsDir() {
-			if strings.Contains(file.Name(), ".sirish.go") {
-				res = append(res, file.Name())
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:  "0.0.1",
+			Imports:  true,
+			CreateTx: true,
+			Closers:  true,
+		},
+	})
+
+	storage := files["Storage.resource_samples.sirish.go"]
+	for _, kind := range []string{"Closer", "ReadCloser", "WriteCloser", "ReadWriteCloser"} {
+		assert.Contains(t, storage, fmt.Sprintf("type storageSirish%s struct", kind))
+	}
+	assert.Contains(t, storage, "NewTxSirishWrapperImpl(w.name")
+	assert.Contains(t, storage, "NewSessionSirishWrapperImpl(w.name")
+
+	tx := files["Tx.resource_samples.sirish.go"]
+	assert.Contains(t, tx, "w.onClose(")
+	assertCompiles(t, filepath.Join(module, samplesPath))
+	runSamples(t, module, closersRuntime)
+
+	// a stream result keeps the span open in place of the closer returned along with it
+	_, files = renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{Imports: true, CreateTx: true, Closers: true, Streams: true},
+	})
+	follow := files["Storage.resource_samples.sirish.go"]
+	follow = follow[strings.Index(follow, ") Follow("):]
+	follow = follow[:strings.Index(follow, "\n}\n")]
+	assert.Contains(t, follow, "streamEnd(")
+	assert.NotContains(t, follow, "lifetime")
+}
+
+// closersRuntime checks Close ends the span of the method which returned the resource once
+const closersRuntime = `package test_samples
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.elastic.co/apm/v2"
+	"go.elastic.co/apm/v2/apmtest"
+)
+
+type closer struct {
+	io.Reader
+	closed int
+}
+
+func (c *closer) Close() error {
+	c.closed++
+	return nil
+}
+
+type storage struct {
+	Storage
+	resource *closer
+}
+
+func (s storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.resource, nil
+}
+
+func (s storage) Follow(ctx context.Context, key string) (<-chan string, io.Closer, error) {
+	return make(chan string), s.resource, nil
+}
+
+func traced(t *testing.T) (*StorageSirishWrapperImpl, *apmtest.RecordingTracer, context.Context, *closer) {
+	tracer := apmtest.NewRecordingTracer()
+	t.Cleanup(tracer.Close)
+	tx := tracer.StartTransaction("test", "test")
+	t.Cleanup(tx.End)
+	resource := &closer{Reader: strings.NewReader("hello")}
+	return NewStorageSirishWrapperImpl("storage", storage{resource: resource}, "test"), tracer, apm.ContextWithTransaction(context.Background(), tx), resource
+}
+
+func TestCloseEndsSpanOnce(t *testing.T) {
+	w, tracer, ctx, resource := traced(t)
+	reader, err := w.Open(ctx, "key")
+	require.NoError(t, err)
+	tracer.Flush(nil)
+	assert.Empty(t, tracer.Payloads().Spans, "the span is open until the reader is closed")
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+	require.NoError(t, reader.Close())
+	require.NoError(t, reader.Close())
+	assert.Equal(t, 2, resource.closed)
+
+	span := endedSpans(t, tracer, 1)[0]
+	assert.Equal(t, "Storage.Open", span.Name)
+	assert.EqualValues(t, 5, label(span, "bytes_read"))
+}
+
+func TestCloseEndsSpanOfStreamWithoutStreams(t *testing.T) {
+	w, tracer, ctx, _ := traced(t)
+	_, resource, err := w.Follow(ctx, "key")
+	require.NoError(t, err)
+	tracer.Flush(nil)
+	assert.Empty(t, tracer.Payloads().Spans, "the span is open until the closer is closed")
+	require.NoError(t, resource.Close())
+	assert.Equal(t, "Storage.Follow", endedSpans(t, tracer, 1)[0].Name)
+}
+`
+
+func TestAPMWrapperCallbacks(t *testing.T) {
+	path := internal.GetTestPathHelper("callback_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Transactional"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:   "0.0.1",
+			Imports:   true,
+			CreateTx:  true,
+			Callbacks: true,
+		},
+	})
+
+	generated := files["callback_samples.sirish.go"]
+	for _, spanName := range []string{"Transactional.WithTx.fn", "Transactional.Each.visit", "Transactional.Later.notify"} {
+		assert.Contains(t, generated, fmt.Sprintf("%q", spanName))
+	}
+	assertCompiles(t, filepath.Join(module, samplesPath))
+	runSamples(t, module, callbacksRuntime)
+}
+
+// callbacksRuntime checks which span the callbacks of Transactional run in, depending on whether they are
+// called before or after the method returned
+const callbacksRuntime = `package test_samples
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.elastic.co/apm/v2"
+	"go.elastic.co/apm/v2/apmtest"
+	"go.elastic.co/apm/v2/model"
+)
+
+type transactional struct {
+	notify func(ctx context.Context, values ...int)
+}
+
+func (s *transactional) WithTx(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error {
+	return fn(ctx, nil)
+}
+
+func (s *transactional) Each(ctx context.Context, keys []string, visit func(context.Context, string) (bool, error)) error {
+	return nil
+}
+
+func (s *transactional) Later(notify func(ctx context.Context, values ...int)) error {
+	s.notify = notify
+	return nil
+}
+
+func recording(t *testing.T) *apmtest.RecordingTracer {
+	tracer := apmtest.NewRecordingTracer()
+	previous := apm.DefaultTracer()
+	apm.SetDefaultTracer(tracer.Tracer)
+	t.Cleanup(func() {
+		apm.SetDefaultTracer(previous)
+		tracer.Close()
+	})
+	return tracer
+}
+
+func spanNamed(t *testing.T, spans []model.Span, name string) model.Span {
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.Failf(t, "missing span", "no span named %s", name)
+	return model.Span{}
+}
+
+func TestCallbackBeforeReturnIsChildSpan(t *testing.T) {
+	tracer := recording(t)
+	tx := tracer.StartTransaction("test", "test")
+	ctx := apm.ContextWithTransaction(context.Background(), tx)
+	wrapper := NewTransactionalSirishWrapperImpl("transactional", &transactional{}, "test")
+
+	require.NoError(t, wrapper.WithTx(ctx, func(ctx context.Context, tx Tx) error { return nil }))
+	tx.End()
+
+	spans := endedSpans(t, tracer, 2)
+	method := spanNamed(t, spans, "Transactional.WithTx")
+	callback := spanNamed(t, spans, "Transactional.WithTx.fn")
+	assert.Equal(t, method.ID, callback.ParentID)
+	assert.Empty(t, callback.Links)
+}
+
+func TestCallbackAfterReturnIsLinkedTransaction(t *testing.T) {
+	tracer := recording(t)
+	impl := &transactional{}
+	wrapper := NewTransactionalSirishWrapperImpl("transactional", impl, "test")
+
+	require.NoError(t, wrapper.Later(func(ctx context.Context, values ...int) {}))
+	require.NotNil(t, impl.notify)
+	impl.notify(context.Background(), 1, 2)
+
+	spans := endedSpans(t, tracer, 2)
+	method := spanNamed(t, spans, "LaterSpan")
+	callback := spanNamed(t, spans, "Transactional.Later.notify")
+	assert.NotEqual(t, method.ID, callback.ParentID)
+
+	var late model.Transaction
+	require.Eventually(t, func() bool {
+		tracer.Flush(nil)
+		for _, transaction := range tracer.Payloads().Transactions {
+			if transaction.Name == "Transactional.Later.notify" {
+				late = transaction
+				return true
 			}
 		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, late.ID, callback.ParentID)
+	assert.Equal(t, late.TraceID, callback.TraceID)
+	require.Len(t, late.Links, 1)
+	assert.Equal(t, method.TraceID, late.Links[0].TraceID)
+	assert.Equal(t, method.ID, late.Links[0].SpanID)
+}
+`
+
+func TestAPMWrapperSkipsUnwrapOfInterface(t *testing.T) {
+	path := internal.GetTestPathHelper("unwrap_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Unwrapper"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:  "0.0.1",
+			Imports:  true,
+			CreateTx: true,
+		},
+	})
+
+	generated := files["unwrap_samples.sirish.go"]
+	assert.Contains(t, generated, "var _ Unwrapper = (*UnwrapperSirishWrapperImpl)(nil)")
+	assert.NotContains(t, generated, "Unwrap() Unwrapper")
+	assertCompiles(t, filepath.Join(module, samplesPath))
+}
+
+func TestAPMWrapperIdentifierHygiene(t *testing.T) {
+	type scenario struct {
+		name      string
+		filename  string
+		iface     string
+		generated string
+		contains  []string
+	}
+	scenarios := []scenario{
+		{
+			name:      "ShadowedParams",
+			filename:  "shadowed_params_samples.go",
+			iface:     "ShadowedParams",
+			generated: "shadowed_params_samples.sirish.go",
+			contains: []string{
+				"func (w1 *ShadowedParamsSirishWrapperImpl) Receiver(",              // receiver
+				"tx1 := apm1.DefaultTracer().StartTransaction(",                     // tx local and apm alias
+				"apm1 \"go.elastic.co/apm/v2\"",                                     // apm alias
+				"context1 \"context\"",                                              // context alias
+				"ctx_0_0 context1.Context, span string",                             // rewritten qualified types
+				"var span1 *apm1.Span",                                              // span
+				"var streamOnce sync1.Once",                                         // sync alias
+				"streamIn1 := items",                                                // stream locals
+				"func (w *ShadowedParamsSirishWrapperImpl) Unwrap() ShadowedParams", // untouched outside methods
+			},
+		},
+		{
+			name:      "ShadowedImports",
+			filename:  "shadowed_imports_samples.go",
+			iface:     "ShadowedImports",
+			generated: "shadowed_imports_samples.sirish.go",
+			contains: []string{
+				"tx1 := apm.DefaultTracer().StartTransaction(", // tx import would be shadowed
+				"make(chan *tx.Builder, cap(streamIn))",
+				"sync1 \"sync\"", // sync name is taken by another package
+				"var streamOnce sync1.Once",
+			},
+		},
+	}
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			path := internal.GetTestPathHelper(s.filename, "")
+			typeVisitor := visitors.NewTypeVisitor(path, dto.Types{s.iface})
+			err := typeVisitor.Traverse()
+			require.NoError(t, err)
+
+			apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+			module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+				GeneralOptions: GeneralOptions{
+					Version:   "0.0.1",
+					Imports:   true,
+					CreateTx:  true,
+					Streams:   true,
+					Callbacks: true,
+				},
+			})
+
+			generated := files[s.generated]
+			for _, want := range s.contains {
+				assert.Contains(t, generated, want)
+			}
+			assertCompiles(t, filepath.Join(module, samplesPath))
+		})
+	}
+}
+
+func TestAPMWrapperWithoutFormatting(t *testing.T) {
+	path := internal.GetTestPathHelper("imports_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"UsedImports"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:   "0.0.1",
+			Imports:   false,
+			CreateTx:  true,
+			Streams:   true,
+			Closers:   true,
+			Callbacks: true,
+		},
+	})
+
+	generated := files["imports_samples.sirish.go"]
+	assert.NotContains(t, generated, "net/http")
+	assert.NotContains(t, generated, "crypto/rand")
+	for _, want := range []string{"context \"context\"", "io \"io\"", "rand \"math/rand\"", "sync \"sync\"", "atomic \"sync/atomic\""} {
+		assert.Contains(t, generated, want)
+	}
+	assertCompiles(t, filepath.Join(module, samplesPath))
+}
+
+func TestAPMWrapperOutputPackage(t *testing.T) {
+	path := internal.GetTestPathHelper("output_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Ledger", "LedgerBatch"})
+	typeVisitor.SetOutput("tracing", "")
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:   "0.0.1",
+			Imports:   true,
+			CreateTx:  true,
+			Streams:   true,
+			Closers:   true,
+			Callbacks: true,
+		},
+	})
+
+	ledger := files["Ledger.output_samples.sirish.go"]
+	for _, want := range []string{
+		"package tracing",
+		"test_samples \"github.com/pm1381/sirish/internal/wrapper/test_samples\"",
+		"var _ test_samples.Ledger = (*LedgerSirishWrapperImpl)(nil)",
+		"entries []test_samples.Entry",
+		"NewLedgerBatchSirishWrapperImpl(w.name",
+	} {
+		assert.Contains(t, ledger, want)
+	}
+	assertCompiles(t, filepath.Join(module, samplesPath, "tracing"))
+}
+
+func TestAPMWrapperExternalInterfaces(t *testing.T) {
+	outDir, err := filepath.Abs(filepath.Join("test_samples", "external"))
+	require.NoError(t, err)
+	interfaces, err := visitors.TraverseExternal(nil, ".", []visitors.ExternalTarget{
+		{Path: "database/sql/driver", Name: "Conn"},
+		{Path: "database/sql/driver", Name: "Tx"},
+		{Path: "github.com/labstack/echo/v4", Name: "Renderer"},
+		{Path: "go.elastic.co/apm/v2", Name: "Logger"},
+		{Path: "io", Name: "ReadCloser"},
+		{Path: "io/fs", Name: "ReadDirFile"},
+	}, outDir, "", 0)
+	require.NoError(t, err)
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, interfaces)
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:   "0.0.1",
+			Imports:   true,
+			CreateTx:  true,
+			Streams:   true,
+			Closers:   true,
+			Callbacks: true,
+		},
+	})
+
+	conn := files["Conn.driver.sirish.go"]
+	for _, want := range []string{
+		"package external",
+		"var _ driver.Conn = (*ConnSirishWrapperImpl)(nil)",
+		"Prepare(query string) (",
+		"driver.Stmt",
+		"NewTxSirishWrapperImpl(w.name",
+	} {
+		assert.Contains(t, conn, want)
+	}
+	logger := files["Logger.logger.sirish.go"]
+	assert.Contains(t, logger, "w.wrapped.Debugf(format, args...)")
+	assert.NotContains(t, logger, "apm1") // the wrapped package is the tracing one
+
+	// the methods of io.ReadCloser are all embedded
+	readCloser := files["ReadCloser.io.sirish.go"]
+	assert.Contains(t, readCloser, "var _ io.ReadCloser = (*ReadCloserSirishWrapperImpl)(nil)")
+	assert.Contains(t, readCloser, ") Read(")
+	assert.Contains(t, readCloser, ") Close() error")
+	assert.Contains(t, files["ReadDirFile.fs.sirish.go"], ") ReadDir(")
+	assertCompiles(t, filepath.Join(module, samplesPath, "external"))
+}
+
+func TestAPMWrapperLocalEmbedded(t *testing.T) {
+	path := internal.GetTestPathHelper("embedded_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Archive"})
+	require.NoError(t, typeVisitor.Traverse())
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:   "0.0.1",
+			Imports:   true,
+			CreateTx:  true,
+			Closers:   true,
+			Callbacks: true,
+		},
+	})
+
+	generated := files["embedded_samples.sirish.go"]
+	for _, want := range []string{
+		"var _ Archive = (*ArchiveSirishWrapperImpl)(nil)",
+		") Close() error",
+		") Find(ctx_0_0 context.Context, key string) ([]byte, error)",
+		") Store(ctx_0_0 context.Context, key string, value []byte) error",
+	} {
+		assert.Contains(t, generated, want)
+	}
+	assertCompiles(t, filepath.Join(module, samplesPath))
+}
+
+func TestAPMWrapperStructs(t *testing.T) {
+	path := internal.GetTestPathHelper("struct_samples.go", "")
+	commentVisitor := visitors.NewCommentVisitor(path)
+	commentVisitor.Traverse()
+	typeVisitor := visitors.NewTypeVisitor(path, nil)
+	typeVisitor.SetStructs(commentVisitor.GetStructs())
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:   "0.0.1",
+			Imports:   true,
+			CreateTx:  true,
+			Streams:   true,
+			Closers:   true,
+			Callbacks: true,
+		},
+	})
+
+	generated := files["struct_samples.sirish.go"]
+	for _, want := range []string{
+		"type BillingInterface interface {",
+		"Export(ctx context.Context, w io.Writer) error",
+		"var _ BillingInterface = (*Billing)(nil)",
+		"var _ BillingInterface = (*BillingInterfaceSirishWrapperImpl)(nil)",
+	} {
+		assert.Contains(t, generated, want)
+	}
+	assertCompiles(t, filepath.Join(module, samplesPath))
+
+	// the generated interface does not make the struct declare it twice on the next run
+	typeVisitor = visitors.NewTypeVisitor(path, nil)
+	typeVisitor.SetStructs(commentVisitor.GetStructs())
+	require.NoError(t, typeVisitor.Traverse())
+}
+
+func TestAPMWrapperPromotedStructMethods(t *testing.T) {
+	path := internal.GetTestPathHelper("struct_embedded_samples.go", "")
+	commentVisitor := visitors.NewCommentVisitor(path)
+	commentVisitor.Traverse()
+	typeVisitor := visitors.NewTypeVisitor(path, nil)
+	typeVisitor.SetStructs(commentVisitor.GetStructs())
+	require.NoError(t, typeVisitor.Traverse())
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:   "0.0.1",
+			Imports:   true,
+			CreateTx:  true,
+			Streams:   true,
+			Closers:   true,
+			Callbacks: true,
+		},
+	})
+
+	generated := files["struct_embedded_samples.sirish.go"]
+	for _, want := range []string{
+		"var _ AuditedBillingInterface = (*AuditedBilling)(nil)",
+		") Audit(",
+		") Charge(",
+		") Export(",
+		") Close() error",
+	} {
+		assert.Contains(t, generated, want)
+	}
+	assertCompiles(t, filepath.Join(module, samplesPath))
+}
+
+func TestAPMWrapperFuncTypes(t *testing.T) {
+	path := internal.GetTestPathHelper("func_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Handler", "Retry", "Lookup"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{
+			Version:   "0.0.1",
+			Imports:   true,
+			CreateTx:  true,
+			Streams:   true,
+			Callbacks: true,
+		},
+	})
+
+	handler := files["Handler.func_samples.sirish.go"]
+	for _, want := range []string{
+		"func TraceHandler(name string, fn Handler) Handler {",
+		"type handlerSirishWrapperImpl struct",
+		":= w.wrapped(ctx_0_0, msg)",
+		"span.Outcome = \"failure\"",
+	} {
+		assert.Contains(t, handler, want)
+	}
+	assert.NotContains(t, handler, "Unwrap")
+
+	lookup := files["Lookup.func_samples.sirish.go"]
+	assert.Contains(t, lookup, "StartTransaction(\"Lookup\"")
+	assertCompiles(t, filepath.Join(module, samplesPath))
+}
+
+func TestAPMWrapperLabelsAndSpanType(t *testing.T) {
+	path := internal.GetTestPathHelper("func_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Handler", "Lookup"})
+	err := typeVisitor.Traverse()
+	require.NoError(t, err)
+
+	general := GeneralOptions{
+		Version:  "0.0.1",
+		Imports:  true,
+		CreateTx: true,
+		SpanType: "messaging",
+		Labels:   map[string]string{"team": "payments"},
+	}
+	lookup := general
+	lookup.SpanType = "cache"
+	lookup.Labels = nil
+	apmW := NewApmWrapper("sirish", "wrapper.gotmpl", templates.FS, typeVisitor.GetWrappedInterfaces())
+	module, files := renderSamples(t, apmW, APMTypeWrapperOptions{
+		GeneralOptions: general,
+		Interfaces:     map[string]GeneralOptions{"Lookup": lookup},
+	})
+
+	handlerFile := files["Handler.func_samples.sirish.go"]
+	assert.Contains(t, handlerFile, `"messaging",`)
+	assert.Contains(t, handlerFile, `.Context.SetLabel("team", "payments")`)
+
+	lookupFile := files["Lookup.func_samples.sirish.go"]
+	assert.Contains(t, lookupFile, `"cache",`)
+	assert.NotContains(t, lookupFile, `"team"`)
+	assertCompiles(t, filepath.Join(module, samplesPath))
+}
+
+// assertCompiles type checks the package in dir including the generated wrappers
+func assertCompiles(t *testing.T, dir string) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedImports | packages.NeedDeps | packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Dir:  dir,
+	}, ".")
+	require.NoError(t, err)
+	require.Len(t, pkgs, 1)
+	for _, pkgErr := range pkgs[0].Errors {
+		t.Error(pkgErr)
 	}
-	return res
 }
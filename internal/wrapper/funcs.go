@@ -0,0 +1,236 @@
+package wrapper
+
+import (
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/visitors"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// FuncMap is available to the embedded and the custom templates, so they can build what they need
+// from TemplateData instead of relying on pre-joined fields
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		// case conversion
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"lowerFirst": lowerFirst,
+		"upperFirst": upperFirst,
+		"camelCase":  camelCase,
+		"pascalCase": pascalCase,
+		"snakeCase":  snakeCase,
+		"kebabCase":  kebabCase,
+
+		// joins of method signatures
+		"join":        join,
+		"params":      params,
+		"args":        args,
+		"results":     results,
+		"resultNames": resultNames,
+		"resultTypes": resultTypes,
+		"zeros":       zeros,
+
+		// types
+		"qualify": qualify,
+		"zero":    visitors.ZeroValue,
+
+		// predicates
+		"hasCtx":     func(m dto.Method) bool { return m.HasCtx },
+		"hasError":   func(m dto.Method) bool { return m.HasError },
+		"hasResults": func(m dto.Method) bool { return len(m.Results) > 0 },
+		"isVariadic": isVariadic,
+
+		// quoting
+		"quote":     strconv.Quote,
+		"backquote": func(s string) string { return "`" + s + "`" },
+	}
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// words splits s on separators and case changes, keeping acronyms like HTTP together
+func words(s string) []string {
+	var res []string
+	runes := []rune(s)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				res = append(res, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		lowerToUpper := unicode.IsUpper(r) && !unicode.IsUpper(prev)
+		acronymEnd := unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if lowerToUpper || acronymEnd {
+			res = append(res, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		res = append(res, string(runes[start:]))
+	}
+	return res
+}
+
+func pascalCase(s string) string {
+	var b strings.Builder
+	for _, word := range words(s) {
+		b.WriteString(upperFirst(strings.ToLower(word)))
+	}
+	return b.String()
+}
+
+func camelCase(s string) string {
+	return lowerFirst(pascalCase(s))
+}
+
+func snakeCase(s string) string {
+	return strings.ToLower(strings.Join(words(s), "_"))
+}
+
+func kebabCase(s string) string {
+	return strings.ToLower(strings.Join(words(s), "-"))
+}
+
+// join joins strings, or the names of params and results
+func join(sep string, list any) string {
+	var items []string
+	switch list := list.(type) {
+	case []string:
+		items = list
+	case []dto.ParamInfo:
+		for _, param := range list {
+			items = append(items, param.Name)
+		}
+	case []dto.ResultInfo:
+		for _, result := range list {
+			items = append(items, result.Name)
+		}
+	}
+	return strings.Join(items, sep)
+}
+
+// params declares the params of m, like a string, b ...int
+func params(m dto.Method) string {
+	var items []string
+	for _, param := range m.Params {
+		items = append(items, param.Name+" "+param.Type)
+	}
+	return strings.Join(items, ", ")
+}
+
+// args passes the params of m to another call, spreading a variadic one
+func args(m dto.Method) string {
+	res := join(", ", m.Params)
+	if isVariadic(m) {
+		res += "..."
+	}
+	return res
+}
+
+// results declares the results of m as they go after the params, named only if m names them
+func results(m dto.Method) string {
+	switch {
+	case len(m.Results) == 0:
+		return ""
+	case m.HasNamedResult:
+		var items []string
+		for _, result := range m.Results {
+			items = append(items, result.Name+" "+result.Type)
+		}
+		return "(" + strings.Join(items, ", ") + ")"
+	case len(m.Results) == 1:
+		return m.Results[0].Type
+	}
+	return "(" + resultTypes(m) + ")"
+}
+
+func resultNames(m dto.Method) string {
+	return join(", ", m.Results)
+}
+
+func resultTypes(m dto.Method) string {
+	var items []string
+	for _, result := range m.Results {
+		items = append(items, result.Type)
+	}
+	return strings.Join(items, ", ")
+}
+
+// zeros lists the zero values of the results of m, to return early without calling the wrapped method
+func zeros(m dto.Method) string {
+	var items []string
+	for _, result := range m.Results {
+		items = append(items, result.Zero)
+	}
+	return strings.Join(items, ", ")
+}
+
+func isVariadic(m dto.Method) bool {
+	return len(m.Params) > 0 && strings.HasPrefix(m.Params[len(m.Params)-1].Type, "...")
+}
+
+// qualify refers to the exported types declared by the package of typeExpr through pkg, e.g.
+// qualify "store" "map[string]*User" gives map[string]*store.User. qualified and builtin types are kept,
+// and so are the type params of the interface when its TypeParams are given, like in
+// qualify "store" "map[K]User" .Interface.TypeParams
+func qualify(pkg string, typeExpr string, typeParams ...[]dto.TypeParamInfo) (string, error) {
+	expr, err := parser.ParseExpr(typeExpr)
+	if err != nil {
+		return "", err
+	}
+	params := make(map[string]bool)
+	for _, list := range typeParams {
+		for _, param := range list {
+			params[param.Name] = true
+		}
+	}
+	qualified := astutil.Apply(expr, func(c *astutil.Cursor) bool {
+		switch c.Parent().(type) {
+		case *ast.SelectorExpr:
+			return false // already qualified
+		case *ast.Field:
+			if c.Name() == "Names" {
+				return false
+			}
+		}
+		ident, ok := c.Node().(*ast.Ident)
+		if ok && ident.IsExported() && types.Universe.Lookup(ident.Name) == nil && !params[ident.Name] {
+			c.Replace(&ast.SelectorExpr{X: ast.NewIdent(pkg), Sel: ast.NewIdent(ident.Name)})
+		}
+		return true
+	}, nil)
+	var b strings.Builder
+	if err = printer.Fprint(&b, token.NewFileSet(), qualified); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
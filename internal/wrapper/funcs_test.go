@@ -0,0 +1,147 @@
+package wrapper
+
+import (
+	"bytes"
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/visitors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCaseConversionWorks(t *testing.T) {
+	type expected struct {
+		camel, pascal, snake, kebab string
+	}
+	scenarios := map[string]expected{
+		"UserService":  {camel: "userService", pascal: "UserService", snake: "user_service", kebab: "user-service"},
+		"HTTPServer":   {camel: "httpServer", pascal: "HttpServer", snake: "http_server", kebab: "http-server"},
+		"user_id":      {camel: "userId", pascal: "UserId", snake: "user_id", kebab: "user-id"},
+		"get-by-ID":    {camel: "getById", pascal: "GetById", snake: "get_by_id", kebab: "get-by-id"},
+		"profileStore": {camel: "profileStore", pascal: "ProfileStore", snake: "profile_store", kebab: "profile-store"},
+	}
+	for s, want := range scenarios {
+		assert.Equal(t, want.camel, camelCase(s), s)
+		assert.Equal(t, want.pascal, pascalCase(s), s)
+		assert.Equal(t, want.snake, snakeCase(s), s)
+		assert.Equal(t, want.kebab, kebabCase(s), s)
+	}
+}
+
+func TestQualifyWorks(t *testing.T) {
+	scenarios := map[string]string{
+		"User":                      "store.User",
+		"*User":                     "*store.User",
+		"map[string][]User":         "map[string][]store.User",
+		"func(ctx Context) error":   "func(ctx store.Context) error",
+		"context.Context":           "context.Context",
+		"string":                    "string",
+		"user":                      "user",
+		"iter.Seq2[ID, *User]":      "iter.Seq2[store.ID, *store.User]",
+		"struct{ Name Name }":       "struct{ Name store.Name }",
+		"chan<- Event":              "chan<- store.Event",
+		"interface{ Get() Record }": "interface{ Get() store.Record }",
+	}
+	for typeExpr, want := range scenarios {
+		got, err := qualify("store", typeExpr)
+		require.NoError(t, err, typeExpr)
+		assert.Equal(t, want, got, typeExpr)
+	}
+	_, err := qualify("store", "map[")
+	assert.Error(t, err)
+
+	// type params of the interface are not declared by the package
+	typeParams := []dto.TypeParamInfo{{Name: "K", Constraint: "comparable"}, {Name: "V", Constraint: "any"}}
+	got, err := qualify("store", "func(K) (map[K]V, *Page[V], error)", typeParams)
+	require.NoError(t, err)
+	assert.Equal(t, "func(K) (map[K]V, *store.Page[V], error)", got)
+}
+
+func TestSignatureFuncsWorks(t *testing.T) {
+	typeVisitor := visitors.NewTypeVisitor(internal.GetTestPathHelper("imports_samples.go", ""), dto.Types{"UsedImports"})
+	require.NoError(t, typeVisitor.Traverse())
+	methods := typeVisitor.GetWrappedInterfaces()[0].Methods
+
+	for _, m := range methods {
+		assert.Equal(t, m.ParamsOverallNames, params(m), m.Name)
+		assert.Equal(t, m.ResultNames, resultNames(m), m.Name)
+		assert.Equal(t, m.ResultTypesNames, resultTypes(m), m.Name)
+		assert.Equal(t, len(m.Results) > 0, FuncMap()["hasResults"].(func(dto.Method) bool)(m), m.Name)
+	}
+
+	variadic := dto.Method{
+		Params:  []dto.ParamInfo{{Name: "format", Type: "string"}, {Name: "args", Type: "...any"}},
+		Results: []dto.ResultInfo{{Name: "n", Type: "int"}, {Name: "err", Type: "error"}},
+	}
+	assert.True(t, isVariadic(variadic))
+	assert.Equal(t, "format, args...", args(variadic))
+	assert.Equal(t, "(int, error)", results(variadic))
+	variadic.HasNamedResult = true
+	assert.Equal(t, "(n int, err error)", results(variadic))
+	assert.Equal(t, "error", results(dto.Method{Results: []dto.ResultInfo{{Name: "r", Type: "error"}}}))
+	assert.Equal(t, "", results(dto.Method{}))
+}
+
+func TestFuncMapInTemplatesWorks(t *testing.T) {
+	f := fstest.MapFS{"custom.gotmpl": {Data: []byte(
+		`{{ $m := index .Interface.Methods 0 }}` +
+			`{{ snakeCase .Interface.Name }} {{ quote $m.Name }} func({{ params $m }}) {{ results $m }} ` +
+			`{{ zero "string" }} {{ qualify "src" "*Account" }} {{ qualify "src" "[]T" .Interface.TypeParams }} ` +
+			`{{ hasCtx $m }} {{ join "|" $m.Params }}`,
+	)}}
+	tmpl, err := ParseTemplate(f, "custom.gotmpl")
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	err = tmpl.Execute(buf, TemplateData{Interface: dto.InterfaceInfo{
+		Name:       "AccountStore",
+		TypeParams: []dto.TypeParamInfo{{Name: "T", Constraint: "any"}},
+		Methods: []dto.Method{{
+			Name:    "Get",
+			HasCtx:  true,
+			Params:  []dto.ParamInfo{{Name: "ctx", Type: "context.Context"}, {Name: "id", Type: "string"}},
+			Results: []dto.ResultInfo{{Name: "res", Type: "*Account"}, {Name: "err", Type: "error"}},
+		}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, `account_store "Get" func(ctx context.Context, id string) (*Account, error) "" *src.Account []T true ctx|id`, buf.String())
+}
+
+func TestZerosCompiles(t *testing.T) {
+	path := internal.GetTestPathHelper("zero_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Pricing"})
+	require.NoError(t, typeVisitor.Traverse())
+
+	// returns early from every method, like a circuit breaker would
+	f := fstest.MapFS{"zeros.gotmpl": {Data: []byte(`package {{ .Interface.OutPackage }}
+
+import (
+{{- range $path, $alias := .Imports }}
+	{{ $alias }} {{ quote $path }}
+{{- end }}
+)
+{{ range $m := .Interface.Methods }}
+func {{ $.HelperPrefix }}{{ $m.Name }}({{ params $m }}) {{ results $m }} {
+	return {{ zeros $m }}
+}
+{{ end }}`)}}
+	tmpl, err := ParseTemplate(f, "zeros.gotmpl")
+	require.NoError(t, err)
+	module, files := renderSamples(t, NewApmWrapperWithTemplate("sirish", tmpl, typeVisitor.GetWrappedInterfaces()), APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{Imports: true},
+	})
+
+	generated := files["zero_samples.sirish.go"]
+	for _, want := range []string{
+		"return 0, nil",
+		"return Quote{}, QuoteAlias{}, nil, nil",
+		"return nil, nil, [2]Cents{}, nil",
+		`return *new(time.Time), false, ""`,
+	} {
+		assert.Contains(t, generated, want)
+	}
+	assertCompiles(t, filepath.Join(module, samplesPath))
+}
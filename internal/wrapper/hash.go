@@ -0,0 +1,201 @@
+package wrapper
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/visitors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// HashPrefix starts the header line holding the source hash of a wrapper, right after its Code generated line
+const HashPrefix = "// sirish-hash: "
+
+// SourcePrefix starts the header line naming the file whose targets a wrapper is generated from, relative to
+// the directory of the wrapper
+const SourcePrefix = "// sirish-source: "
+
+// DirectivePrefix starts the header line identifying the go:generate directive of the source file a wrapper
+// comes from. with the source line, it tells which wrappers a run owns when one of its targets is gone
+const DirectivePrefix = "// sirish-directive: "
+
+// modulePath is the module sirish is built from, as a binary or a library
+const modulePath = "github.com/pm1381/sirish"
+
+// FileHash is where a wrapper goes and the hash of what it is generated from
+type FileHash struct {
+	Path      string
+	Interface string
+	Hash      string
+}
+
+// HashingWrapper is implemented by the wrappers whose files carry their source hash
+type HashingWrapper interface {
+	WrapperInterface
+	Hashes(opts Options) ([]FileHash, error) // the files Render returns with their hashes, without rendering them
+}
+
+var generatorVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	return releaseVersion(info)
+})
+
+// releaseVersion is the version of sirish in info, without the vcs settings: rebuilding a release from another
+// commit or a dirty tree does not make every wrapper stale. what the templates render is identified by their digest
+func releaseVersion(info *debug.BuildInfo) string {
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			if dep.Version == "" {
+				return "(devel)" // replaced by a directory
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// GeneratorVersion is the version of sirish the running binary is built with, (devel) for local builds
+func GeneratorVersion() string {
+	return generatorVersion()
+}
+
+// TemplateDigest identifies the parsed text of tmpl and the templates it defines
+func TemplateDigest(tmpl *template.Template) string {
+	templates := tmpl.Templates()
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name() < templates[j].Name() })
+	hash := sha256.New()
+	for _, t := range templates {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		hash.Write([]byte(t.Name() + "\x00" + t.Tree.Root.String() + "\x00"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// SourceHash is the hash of everything the wrapper of info is generated from: its declaration, options
+// and suffix, the templates identified by generator, the directive and the sirish version. positions and
+// absolute paths are left out, moving a declaration or the checkout does not change its wrapper
+func SourceHash(info dto.InterfaceInfo, options GeneralOptions, suffix string, generator string, directive string) (string, error) {
+	info.FilePath, info.Directory, info.OutDirectory = "", "", ""
+	info.Position = dto.Position{}
+	methods := make([]dto.Method, len(info.Methods))
+	for i, method := range info.Methods {
+		method.Position = dto.Position{}
+		methods[i] = method
+	}
+	info.Methods = methods
+	src, err := json.Marshal(struct {
+		Version   string
+		Generator string
+		Directive string
+		Suffix    string
+		Options   GeneralOptions
+		Interface dto.InterfaceInfo
+	}{GeneratorVersion(), generator, directive, suffix, options, info})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Header is what the top of a go file tells about the run which generated it
+type Header struct {
+	Generated bool   // starts with the Code generated line of sirish
+	Hash      string // source hash, see HashPrefix
+	Source    string // absolute path of the file whose targets it is generated from, see SourcePrefix
+	Directive string // directive of Source it is generated by, see DirectivePrefix
+}
+
+// ReadHeader reads the header of the go file at p, up to its package clause. a missing file has the zero Header
+func ReadHeader(p string) (Header, error) {
+	var header Header
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return header, nil
+	}
+	if err != nil {
+		return header, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for first := true; scanner.Scan(); first = false {
+		line := scanner.Text()
+		if first {
+			header.Generated = strings.HasPrefix(line, "// "+visitors.GeneratedHeader)
+		}
+		if hash, ok := strings.CutPrefix(line, HashPrefix); ok {
+			header.Hash = strings.TrimSpace(hash)
+		} else if source, ok := strings.CutPrefix(line, SourcePrefix); ok {
+			header.Source = filepath.Join(filepath.Dir(p), filepath.FromSlash(strings.TrimSpace(source)))
+		} else if directive, ok := strings.CutPrefix(line, DirectivePrefix); ok {
+			header.Directive = strings.TrimSpace(directive)
+		} else if line != "" && !strings.HasPrefix(line, "//") {
+			break // the header ends at the package clause
+		}
+	}
+	return header, scanner.Err()
+}
+
+// ReadHash returns the source hash in the header of the file at p, empty when it has none or does not exist
+func ReadHash(p string) (string, error) {
+	header, err := ReadHeader(p)
+	return header.Hash, err
+}
+
+// sourceLine is the SourcePrefix line of a wrapper at p generated from source, empty without a source
+func sourceLine(p string, source string) string {
+	if source == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(filepath.Dir(p), source)
+	if err != nil {
+		rel = source
+	}
+	return SourcePrefix + filepath.ToSlash(rel)
+}
+
+// directiveLine is the DirectivePrefix line of a wrapper generated by directive, empty without one
+func directiveLine(directive string) string {
+	if directive == "" {
+		return ""
+	}
+	return DirectivePrefix + directive
+}
+
+// stampHeader adds lines after the Code generated line content starts with, skipping the empty ones.
+// templates without that line are left as they are, their wrappers are always rendered
+func stampHeader(content []byte, lines ...string) []byte {
+	end := bytes.IndexByte(content, '\n')
+	if !bytes.HasPrefix(content, []byte("// Code generated ")) || end < 0 {
+		return content
+	}
+	res := make([]byte, 0, len(content)+128)
+	res = append(res, content[:end+1]...)
+	for _, line := range lines {
+		if line != "" {
+			res = append(res, line+"\n"...)
+		}
+	}
+	return append(res, content[end+1:]...)
+}
@@ -0,0 +1,158 @@
+package wrapper
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/templates"
+	"github.com/pm1381/sirish/internal/visitors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+	"text/template"
+)
+
+func TestSourceHash(t *testing.T) {
+	info := dto.InterfaceInfo{
+		Name:     "Store",
+		Position: dto.Position{File: "store.go", Line: 3, Column: 6},
+		Methods:  []dto.Method{{Name: "Get", Position: dto.Position{File: "store.go", Line: 4, Column: 2}}},
+	}
+	hash, err := SourceHash(info, GeneralOptions{CreateTx: true}, "sirish", "digest", "")
+	require.NoError(t, err)
+
+	moved := info
+	moved.Position.Line = 30
+	moved.Methods = []dto.Method{{Name: "Get", Position: dto.Position{File: "store.go", Line: 31, Column: 2}}}
+	same, err := SourceHash(moved, GeneralOptions{CreateTx: true}, "sirish", "digest", "")
+	require.NoError(t, err)
+	assert.Equal(t, hash, same, "positions do not change the wrapper")
+
+	checkout := info
+	checkout.FilePath, checkout.Directory, checkout.OutDirectory = "/ci/src/store.go", "/ci/src", "/ci/src"
+	same, err = SourceHash(checkout, GeneralOptions{CreateTx: true}, "sirish", "digest", "")
+	require.NoError(t, err)
+	assert.Equal(t, hash, same, "the location of the checkout does not change the wrapper")
+	assert.Equal(t, 4, info.Methods[0].Position.Line, "info is not modified")
+
+	for name, other := range map[string]func() (string, error){
+		"method": func() (string, error) {
+			return SourceHash(dto.InterfaceInfo{Name: "Store"}, GeneralOptions{CreateTx: true}, "sirish", "digest", "")
+		},
+		"options": func() (string, error) { return SourceHash(info, GeneralOptions{}, "sirish", "digest", "") },
+		"suffix": func() (string, error) {
+			return SourceHash(info, GeneralOptions{CreateTx: true}, "traced", "digest", "")
+		},
+		"templates": func() (string, error) { return SourceHash(info, GeneralOptions{CreateTx: true}, "sirish", "other", "") },
+		"directive": func() (string, error) {
+			return SourceHash(info, GeneralOptions{CreateTx: true}, "sirish", "digest", "d1")
+		},
+	} {
+		changed, err := other()
+		require.NoError(t, err)
+		assert.NotEqual(t, hash, changed, name)
+	}
+}
+
+func TestReleaseVersion(t *testing.T) {
+	build := func(revision string, modified string) *debug.BuildInfo {
+		return &debug.BuildInfo{
+			Main:     debug.Module{Path: modulePath, Version: "v1.4.0"},
+			Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: revision}, {Key: "vcs.modified", Value: modified}},
+		}
+	}
+	assert.Equal(t, "v1.4.0", releaseVersion(build("abc", "false")))
+	assert.Equal(t, releaseVersion(build("abc", "false")), releaseVersion(build("def", "true")), "rebuilds keep the hashes")
+
+	library := &debug.BuildInfo{
+		Main: debug.Module{Path: "example.com/tool"},
+		Deps: []*debug.Module{{Path: modulePath, Version: "v1.4.0", Sum: "h1:abc"}},
+	}
+	assert.Equal(t, "v1.4.0", releaseVersion(library))
+	library.Deps[0].Replace = &debug.Module{Path: "../sirish"}
+	assert.Equal(t, "(devel)", releaseVersion(library))
+}
+
+func TestTemplateDigest(t *testing.T) {
+	first := template.Must(template.New("wrapper.gotmpl").Parse(`package {{ .Interface.OutPackage }}`))
+	second := template.Must(template.New("wrapper.gotmpl").Parse(`package {{ .Interface.OutPackage }} // changed`))
+	assert.Equal(t, TemplateDigest(first), TemplateDigest(template.Must(first.Clone())))
+	assert.NotEqual(t, TemplateDigest(first), TemplateDigest(second))
+}
+
+func TestStampAndReadHash(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a.sirish.go")
+	source := filepath.Join(filepath.Dir(filepath.Dir(p)), "a.go")
+	content := stampHeader([]byte("// Code generated by github.com/pm1381/sirish. DO NOT EDIT.\n// Version\n\npackage a\n"),
+		HashPrefix+"abc", sourceLine(p, source), directiveLine("d1"), directiveLine(""))
+	assert.Equal(t, "// Code generated by github.com/pm1381/sirish. DO NOT EDIT.\n"+HashPrefix+"abc\n"+SourcePrefix+"../a.go\n"+
+		DirectivePrefix+"d1\n// Version\n\npackage a\n", string(content))
+	require.NoError(t, os.WriteFile(p, content, 0o644))
+	header, err := ReadHeader(p)
+	require.NoError(t, err)
+	assert.Equal(t, Header{Generated: true, Hash: "abc", Source: source, Directive: "d1"}, header)
+
+	// files without a Code generated line get no hash
+	assert.Equal(t, "package a\n", string(stampHeader([]byte("package a\n"), HashPrefix+"abc")))
+	require.NoError(t, os.WriteFile(p, []byte("package a\n\n"+HashPrefix+"abc\n"), 0o644))
+	header, err = ReadHeader(p)
+	require.NoError(t, err)
+	assert.Equal(t, Header{}, header, "only the header is read")
+
+	hash, err := ReadHash(filepath.Join(filepath.Dir(p), "missing.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "", hash)
+}
+
+func TestRenderIncremental(t *testing.T) {
+	path := internal.GetTestPathHelper("zero_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Pricing"})
+	require.NoError(t, typeVisitor.Traverse())
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	interfaces[0].OutDirectory = t.TempDir()
+
+	apmW := NewApmWrapper("sirish", EntryTemplate, templates.FS, interfaces).(HashingWrapper)
+	opts := APMTypeWrapperOptions{GeneralOptions: GeneralOptions{Imports: true}, Incremental: true}
+	hashes, err := apmW.Hashes(opts)
+	require.NoError(t, err)
+	require.Len(t, hashes, 1)
+
+	files, err := apmW.Render(opts)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, hashes[0].Path, files[0].Path)
+	assert.Contains(t, string(files[0].Content), HashPrefix+hashes[0].Hash+"\n")
+
+	// a file carrying the current hash is read instead of rendered
+	kept := "// Code generated by github.com/pm1381/sirish. DO NOT EDIT.\n" + HashPrefix + hashes[0].Hash + "\n\npackage kept\n"
+	require.NoError(t, os.WriteFile(files[0].Path, []byte(kept), 0o644))
+	files, err = apmW.Render(opts)
+	require.NoError(t, err)
+	assert.Equal(t, kept, string(files[0].Content))
+
+	opts.Incremental = false
+	files, err = apmW.Render(opts)
+	require.NoError(t, err)
+	assert.NotEqual(t, kept, string(files[0].Content))
+}
+
+func TestRenderDoesNotStampBrokenOutput(t *testing.T) {
+	path := internal.GetTestPathHelper("zero_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Pricing"})
+	require.NoError(t, typeVisitor.Traverse())
+	interfaces := typeVisitor.GetWrappedInterfaces()
+	interfaces[0].OutDirectory = t.TempDir()
+
+	// with the hash of its sources, a file which is not go would be kept by every incremental run
+	tmpl := template.Must(template.New(EntryTemplate).Parse("// Code generated by github.com/pm1381/sirish. DO NOT EDIT.\n\npackage {{ .Interface.OutPackage }}\n\nfunc {\n"))
+	apmW := NewApmWrapperWithTemplate("sirish", tmpl, interfaces)
+	_, err := apmW.Render(APMTypeWrapperOptions{GeneralOptions: GeneralOptions{Imports: true}, Incremental: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "formatting wrapper of Pricing")
+
+	err = apmW.Generate(APMTypeWrapperOptions{GeneralOptions: GeneralOptions{Imports: true}, Incremental: true})
+	require.Error(t, err)
+	assert.NoFileExists(t, OutputPath(interfaces[0], "sirish"))
+}
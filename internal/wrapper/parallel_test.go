@@ -0,0 +1,90 @@
+package wrapper
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/templates"
+	"github.com/pm1381/sirish/internal/visitors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// syntheticPackage writes a package of n interfaces, each in its own wrapper file, and returns them parsed
+func syntheticPackage(tb testing.TB, n int) []dto.InterfaceInfo {
+	src := new(strings.Builder)
+	src.WriteString("package synthetic\n\nimport \"context\"\n\n")
+	var names dto.Types
+	for i := range n {
+		name := fmt.Sprintf("Store%03d", i)
+		names = append(names, name)
+		fmt.Fprintf(src, "type %s interface {\n", name)
+		fmt.Fprintf(src, "\tGet(ctx context.Context, id string) (string, error)\n")
+		fmt.Fprintf(src, "\tList(ctx context.Context, limit int) ([]string, error)\n")
+		fmt.Fprintf(src, "\tSave(ctx context.Context, id string, value []byte) error\n")
+		fmt.Fprintf(src, "}\n\n")
+	}
+	dir := tb.TempDir()
+	require.NoError(tb, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/synthetic\n"), 0o644))
+	path := filepath.Join(dir, "stores.go")
+	require.NoError(tb, os.WriteFile(path, []byte(src.String()), 0o644))
+
+	typeVisitor := visitors.NewTypeVisitor(path, names)
+	require.NoError(tb, typeVisitor.Traverse())
+	return typeVisitor.GetWrappedInterfaces()
+}
+
+func TestRenderParallel_Deterministic(t *testing.T) {
+	interfaces := syntheticPackage(t, 40)
+	apmW := NewApmWrapper("sirish", EntryTemplate, templates.FS, interfaces)
+	render := func(jobs int) []File {
+		files, err := apmW.Render(APMTypeWrapperOptions{GeneralOptions: GeneralOptions{Imports: true, CreateTx: true}, Jobs: jobs})
+		require.NoError(t, err)
+		return files
+	}
+	sequential := render(1)
+	require.Len(t, sequential, 40)
+	for i, file := range sequential {
+		assert.Equal(t, interfaces[i].Name, file.Interface)
+	}
+	assert.Equal(t, sequential, render(8))
+}
+
+func TestRenderParallel_JoinsErrors(t *testing.T) {
+	interfaces := syntheticPackage(t, 3)
+	tmpl := template.Must(template.New(EntryTemplate).Parse(
+		`{{ if or (eq .Interface.Name "Store000") (eq .Interface.Name "Store002") }}{{ template "missing" }}{{ end }}`))
+
+	_, err := NewApmWrapperWithTemplate("sirish", tmpl, interfaces).Render(APMTypeWrapperOptions{Jobs: 4})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "generating wrapper of Store000")
+	assert.Contains(t, err.Error(), "generating wrapper of Store002")
+	assert.NotContains(t, err.Error(), "Store001")
+	assert.Less(t, strings.Index(err.Error(), "Store000"), strings.Index(err.Error(), "Store002"))
+}
+
+// BenchmarkRender renders a package of 300 interfaces with one worker, four and GOMAXPROCS of them,
+// go test -bench Render -run ^$ ./internal/wrapper compares them
+func BenchmarkRender(b *testing.B) {
+	interfaces := syntheticPackage(b, 300)
+	apmW := NewApmWrapper("sirish", EntryTemplate, templates.FS, interfaces)
+	jobs := []int{1, 4}
+	if procs := runtime.GOMAXPROCS(0); procs > 4 {
+		jobs = append(jobs, procs)
+	}
+	for _, jobs := range jobs {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			opts := APMTypeWrapperOptions{GeneralOptions: GeneralOptions{Imports: true, CreateTx: true, Streams: true}, Jobs: jobs}
+			for b.Loop() {
+				if _, err := apmW.Render(opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// PluginPrefix starts the executable of every plugin, the otel backend is run as sirish-gen-otel
+const PluginPrefix = "sirish-gen-"
+
+// PluginProtocolVersion is the version of PluginRequest and PluginResponse. it is bumped whenever a field
+// is removed or changes meaning, new fields keep it
+const PluginProtocolVersion = 1
+
+// PluginRequest is written as JSON to the stdin of a plugin
+type PluginRequest struct {
+	ProtocolVersion  int                       `json:"protocol_version"`
+	Backend          string                    `json:"backend"`           // name of the plugin
+	Suffix           string                    `json:"suffix"`            // suffix of the generated files and types
+	Options          GeneralOptions            `json:"options"`           // the flags and config of the run
+	InterfaceOptions map[string]GeneralOptions `json:"interface_options"` // options replacing Options for some interfaces
+	Interfaces       []dto.InterfaceInfo       `json:"interfaces"`        // what sirish inspect -json prints
+}
+
+// PluginResponse is read as JSON from the stdout of a plugin
+type PluginResponse struct {
+	Files []PluginFile `json:"files"`
+	Error string       `json:"error,omitempty"` // fails the run when set
+}
+
+// PluginFile is a file a plugin generated. relative paths are relative to the directory of the parsed file,
+// which is also the working directory of the plugin
+type PluginFile struct {
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Interface string `json:"interface,omitempty"` // name of the interface the file wraps, for messages only
+}
+
+type pluginWrapper struct {
+	name       string
+	dir        string
+	suffix     string
+	interfaces []dto.InterfaceInfo
+}
+
+// NewPluginWrapper generates the wrappers with the sirish-gen-<name> executable found on PATH, run in dir
+func NewPluginWrapper(name string, dir string, suffix string, interfaces []dto.InterfaceInfo) WrapperInterface {
+	if suffix == "" {
+		suffix = "sirish"
+	}
+	return &pluginWrapper{
+		name:       name,
+		dir:        dir,
+		suffix:     suffix,
+		interfaces: interfaces,
+	}
+}
+
+// LookPlugin returns the path of the executable of the plugin name
+func LookPlugin(name string) (string, error) {
+	p, err := exec.LookPath(PluginPrefix + name)
+	if err != nil {
+		return "", fmt.Errorf("plugin %s%s is not found on PATH", PluginPrefix, name)
+	}
+	return p, nil
+}
+
+func (pw *pluginWrapper) Generate(opts Options) error {
+	return pw.GenerateTo(opts, NewDiskSink())
+}
+
+func (pw *pluginWrapper) GenerateTo(opts Options, sink Sink) error {
+	files, err := pw.Render(opts)
+	if err != nil {
+		sink.Close()
+		return err
+	}
+	return WriteFiles(sink, files)
+}
+
+// Render runs the plugin and formats the files it returns, nothing is written
+func (pw *pluginWrapper) Render(opts Options) ([]File, error) {
+	options, ok := opts.(APMTypeWrapperOptions)
+	if !ok {
+		return nil, errors.New("invalid options")
+	}
+	executable, err := LookPlugin(pw.name)
+	if err != nil {
+		return nil, err
+	}
+	interfaces := pw.interfaces
+	if interfaces == nil {
+		interfaces = []dto.InterfaceInfo{}
+	}
+	request, err := json.Marshal(PluginRequest{
+		ProtocolVersion:  PluginProtocolVersion,
+		Backend:          pw.name,
+		Suffix:           pw.suffix,
+		Options:          options.GeneralOptions,
+		InterfaceOptions: options.Interfaces,
+		Interfaces:       interfaces,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := new(bytes.Buffer)
+	cmd := exec.Command(executable)
+	cmd.Dir = pw.dir
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr // the plugin reports progress like sirish does
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", pw.name, err)
+	}
+	var response PluginResponse
+	if err = json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("plugin %s wrote an invalid response: %w", pw.name, err)
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", pw.name, response.Error)
+	}
+
+	// formatting is the slow part, the files are formatted on options.Jobs workers
+	files, err := internal.ParallelMap(options.Jobs, response.Files, func(pluginFile PluginFile) (File, error) {
+		if pluginFile.Path == "" {
+			return File{}, fmt.Errorf("plugin %s returned a file without a path", pw.name)
+		}
+		fullPath := pluginFile.Path
+		if !filepath.IsAbs(fullPath) {
+			fullPath = filepath.Join(pw.dir, fullPath)
+		}
+		content := []byte(pluginFile.Content)
+		if options.Imports && filepath.Ext(fullPath) == ".go" {
+			formatted, err := formatImports(fullPath, content)
+			if err != nil {
+				return File{}, fmt.Errorf("plugin %s returned %s which is not valid go: %w", pw.name, pluginFile.Path, err)
+			}
+			content = formatted
+		}
+		if filepath.Ext(fullPath) == ".go" {
+			content = stampHeader(content, sourceLine(fullPath, options.Source), directiveLine(options.Directive))
+		}
+		return File{Path: fullPath, Content: content, Interface: pluginFile.Interface}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,80 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/visitors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// installPlugin puts a sirish-gen-<name> script running body on PATH
+func installPlugin(t *testing.T, name string, body string) {
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, PluginPrefix+name), []byte("#!/bin/sh\n"+body), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestPluginWrapper(t *testing.T) {
+	installPlugin(t, "echo", `cat > request.json
+printf '%s' '{"files":[{"path":"out/echo.go","content":"package out\n\nfunc Echo() string { return strings.ToUpper(\"x\") }\n","interface":"Handler"}]}'
+`)
+	typeVisitor := visitors.NewTypeVisitor(internal.GetTestPathHelper("func_samples.go", ""), dto.Types{"Handler"})
+	require.NoError(t, typeVisitor.Traverse())
+
+	dir := t.TempDir()
+	files, err := NewPluginWrapper("echo", dir, "traced", typeVisitor.GetWrappedInterfaces()).Render(APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{Imports: true, CreateTx: true, Labels: map[string]string{"team": "payments"}},
+		Interfaces:     map[string]GeneralOptions{"Handler": {SpanType: "messaging"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, filepath.Join(dir, "out", "echo.go"), files[0].Path)
+	assert.Equal(t, "Handler", files[0].Interface)
+	assert.Contains(t, string(files[0].Content), `import "strings"`) // formatted like the apm wrappers
+	assert.NoFileExists(t, files[0].Path)
+
+	src, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	require.NoError(t, err)
+	var request PluginRequest
+	require.NoError(t, json.Unmarshal(src, &request))
+	assert.Equal(t, PluginProtocolVersion, request.ProtocolVersion)
+	assert.Equal(t, "echo", request.Backend)
+	assert.Equal(t, "traced", request.Suffix)
+	assert.Equal(t, map[string]string{"team": "payments"}, request.Options.Labels)
+	assert.Equal(t, "messaging", request.InterfaceOptions["Handler"].SpanType)
+	require.Len(t, request.Interfaces, 1)
+	assert.Equal(t, "Handler", request.Interfaces[0].Name)
+	assert.True(t, request.Interfaces[0].Func)
+}
+
+func TestPluginWrapper_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin string
+		body   string
+		err    string
+	}{
+		{name: "not on path", plugin: "missing", err: "plugin sirish-gen-missing is not found on PATH"},
+		{name: "exit status", plugin: "fails", body: "exit 3\n", err: "plugin fails: exit status 3"},
+		{name: "reported error", plugin: "refuses", body: `echo '{"error":"generics are not supported"}'` + "\n", err: "plugin refuses: generics are not supported"},
+		{name: "invalid response", plugin: "chatty", body: "echo generating\n", err: "plugin chatty wrote an invalid response"},
+		{name: "invalid go", plugin: "broken", body: `echo '{"files":[{"path":"broken.go","content":"package"}]}'` + "\n", err: "plugin broken returned broken.go which is not valid go"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.body != "" {
+				installPlugin(t, test.plugin, test.body)
+			}
+			_, err := NewPluginWrapper(test.plugin, t.TempDir(), "", nil).Render(APMTypeWrapperOptions{
+				GeneralOptions: GeneralOptions{Imports: true},
+			})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
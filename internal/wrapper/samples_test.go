@@ -0,0 +1,113 @@
+package wrapper
+
+import (
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// samplesPath is where the samples live, relative to the root of the module
+var samplesPath = filepath.Join("internal", "wrapper", "test_samples")
+
+// sampleModule copies go.mod, go.sum and the samples without their generated files into a temporary module
+// with the path of this one, so wrappers rendered for the samples are compiled there instead of next to them
+func sampleModule(t *testing.T) string {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	dst := t.TempDir()
+	for _, name := range []string{"go.mod", "go.sum"} {
+		content, err := os.ReadFile(filepath.Join(root, name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dst, name), content, 0o644))
+	}
+	err = filepath.WalkDir("test_samples", func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(p) != ".go" || strings.Contains(entry.Name(), ".sirish.") {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, "internal", "wrapper", p)
+		if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0o644)
+	})
+	require.NoError(t, err)
+	return dst
+}
+
+// renderSamples renders the wrappers of apmW and writes them into a sample module at the place they would
+// take in this one. it returns the root of that module and the contents of the files by name
+func renderSamples(t *testing.T, apmW WrapperInterface, opts APMTypeWrapperOptions) (string, map[string]string) {
+	files, err := apmW.Render(opts)
+	require.NoError(t, err)
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	module := sampleModule(t)
+	contents := make(map[string]string, len(files))
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file.Path)
+		require.NoError(t, err)
+		require.False(t, strings.HasPrefix(rel, ".."), "%s is outside of the module", file.Path)
+		target := filepath.Join(module, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(target), 0o755))
+		require.NoError(t, os.WriteFile(target, file.Content, 0o644))
+		contents[filepath.Base(file.Path)] = string(file.Content)
+	}
+	return module, contents
+}
+
+// runtimeHelpers are shared by the tests runSamples runs
+const runtimeHelpers = `package test_samples
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.elastic.co/apm/v2/apmtest"
+	"go.elastic.co/apm/v2/model"
+)
+
+// endedSpans waits until the tracer recorded n spans, and fails if it records more
+func endedSpans(t *testing.T, tracer *apmtest.RecordingTracer, n int) []model.Span {
+	var spans []model.Span
+	require.Eventually(t, func() bool {
+		tracer.Flush(nil)
+		spans = tracer.Payloads().Spans
+		return len(spans) >= n
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	tracer.Flush(nil)
+	spans = tracer.Payloads().Spans
+	require.Len(t, spans, n)
+	return spans
+}
+
+// label is the value of the label key of span, nil without one
+func label(span model.Span, key string) any {
+	for _, item := range span.Context.Tags {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+`
+
+// runSamples runs src as a test of the samples package in module, next to the wrappers rendered into it
+func runSamples(t *testing.T, module string, src string) {
+	dir := filepath.Join(module, samplesPath)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helpers_test.go"), []byte(runtimeHelpers), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "runtime_test.go"), []byte(src), 0o644))
+	cmd := exec.Command("go", "test", "-count=1", "./"+filepath.ToSlash(samplesPath))
+	cmd.Dir = module
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
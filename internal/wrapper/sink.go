@@ -0,0 +1,258 @@
+package wrapper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink is where generated files go. Close is called once every file is written
+type Sink interface {
+	Write(file File) error
+	Close() error
+}
+
+// WriteFiles writes files to sink and closes it, the first failing file stops the others
+func WriteFiles(sink Sink, files []File) error {
+	for _, file := range files {
+		if err := sink.Write(file); err != nil {
+			sink.Close()
+			return fmt.Errorf("writing %s: %w", file.Path, err)
+		}
+	}
+	return sink.Close()
+}
+
+type diskSink struct{}
+
+// NewDiskSink writes every file to its path, creating the missing directories. a file whose content is
+// already on disk is left alone, so its mtime and the build caches depending on it are kept
+func NewDiskSink() Sink {
+	return diskSink{}
+}
+
+func (diskSink) Write(file File) error {
+	status, err := Compare(file)
+	if err != nil {
+		return err
+	}
+	if status == StatusUnchanged {
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+		return err
+	}
+	return writeAtomic(file.Path, file.Content)
+}
+
+// writeAtomic writes content to a temporary file next to p and renames it to p, so a failed run never
+// leaves half a file behind
+func writeAtomic(p string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails once renamed
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	// CreateTemp makes the file readable by its owner only
+	if err = tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (diskSink) Close() error {
+	return nil
+}
+
+// MemorySink keeps the files by path, for tests and tools which handle the content themselves
+type MemorySink struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemorySink() *MemorySink {
+	return &MemorySink{files: make(map[string][]byte)}
+}
+
+func (ms *MemorySink) Write(file File) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.files[file.Path] = append([]byte(nil), file.Content...)
+	return nil
+}
+
+func (ms *MemorySink) Close() error {
+	return nil
+}
+
+// Files returns a copy of the written files by path
+func (ms *MemorySink) Files() map[string][]byte {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	res := make(map[string][]byte, len(ms.files))
+	for p, content := range ms.files {
+		res[p] = content
+	}
+	return res
+}
+
+type writerSink struct {
+	w io.Writer
+}
+
+// NewWriterSink prints every file to w after a comment naming its path, e.g. to stdout
+func NewWriterSink(w io.Writer) Sink {
+	return writerSink{w: w}
+}
+
+func (ws writerSink) Write(file File) error {
+	_, err := fmt.Fprintf(ws.w, "// sirish: %s\n%s\n", file.Path, file.Content)
+	return err
+}
+
+func (ws writerSink) Close() error {
+	return nil
+}
+
+// archiveSink collects the files so the archive lists them sorted, whatever order they are written in
+type archiveSink struct {
+	root  string
+	files map[string][]byte
+	write func(names []string, files map[string][]byte) error
+}
+
+func (as *archiveSink) Write(file File) error {
+	name, err := archiveName(as.root, file.Path)
+	if err != nil {
+		return err
+	}
+	as.files[name] = file.Content
+	return nil
+}
+
+func (as *archiveSink) Close() error {
+	names := make([]string, 0, len(as.files))
+	for name := range as.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return as.write(names, as.files)
+}
+
+// archiveName is the slash separated path of p relative to root, archives never hold files outside of it
+func archiveName(root string, p string) (string, error) {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the archive root %s", p, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// NewTarSink writes the files to a tar archive on w, named relative to root
+func NewTarSink(w io.Writer, root string) Sink {
+	return &archiveSink{root: root, files: make(map[string][]byte), write: func(names []string, files map[string][]byte) error {
+		tw := tar.NewWriter(w)
+		for _, name := range names {
+			if err := tw.WriteHeader(&tar.Header{
+				Name:    name,
+				Mode:    0o644,
+				Size:    int64(len(files[name])),
+				ModTime: time.Unix(0, 0), // the same files give the same archive
+			}); err != nil {
+				return err
+			}
+			if _, err := tw.Write(files[name]); err != nil {
+				return err
+			}
+		}
+		return tw.Close()
+	}}
+}
+
+// NewZipSink writes the files to a zip archive on w, named relative to root
+func NewZipSink(w io.Writer, root string) Sink {
+	return &archiveSink{root: root, files: make(map[string][]byte), write: func(names []string, files map[string][]byte) error {
+		zw := zip.NewWriter(w)
+		for _, name := range names {
+			entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+			if err != nil {
+				return err
+			}
+			if _, err = entry.Write(files[name]); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	}}
+}
+
+// Status tells what writing a file to disk would do
+type Status string
+
+const (
+	StatusCreate    Status = "create"
+	StatusModify    Status = "modify"
+	StatusUnchanged Status = "unchanged"
+)
+
+// Compare tells what writing file to disk would do
+func Compare(file File) (Status, error) {
+	existing, err := os.ReadFile(file.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return StatusCreate, nil
+	case err != nil:
+		return "", err
+	case bytes.Equal(existing, file.Content):
+		return StatusUnchanged, nil
+	}
+	return StatusModify, nil
+}
+
+type dryRunSink struct {
+	w    io.Writer
+	root string
+}
+
+// NewDryRunSink writes nothing, it prints whether every file would be created, modified or left unchanged,
+// with paths relative to root
+func NewDryRunSink(w io.Writer, root string) Sink {
+	return dryRunSink{w: w, root: root}
+}
+
+func (ds dryRunSink) Write(file File) error {
+	status, err := Compare(file)
+	if err != nil {
+		return err
+	}
+	p := file.Path
+	if rel, err := filepath.Rel(ds.root, p); err == nil && !strings.HasPrefix(rel, "..") {
+		p = rel
+	}
+	_, err = fmt.Fprintf(ds.w, "%-9s %s\n", status, p)
+	return err
+}
+
+func (ds dryRunSink) Close() error {
+	return nil
+}
@@ -0,0 +1,124 @@
+package wrapper
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/templates"
+	"github.com/pm1381/sirish/internal/visitors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateTo_MemorySink(t *testing.T) {
+	path := internal.GetTestPathHelper("zero_samples.go", "")
+	typeVisitor := visitors.NewTypeVisitor(path, dto.Types{"Pricing"})
+	require.NoError(t, typeVisitor.Traverse())
+
+	sink := NewMemorySink()
+	apmW := NewApmWrapper("memory", EntryTemplate, templates.FS, typeVisitor.GetWrappedInterfaces())
+	require.NoError(t, apmW.GenerateTo(APMTypeWrapperOptions{GeneralOptions: GeneralOptions{Imports: true}}, sink))
+
+	generated := filepath.Join(filepath.Dir(path), "zero_samples.memory.go")
+	files := sink.Files()
+	require.Len(t, files, 1)
+	assert.Contains(t, string(files[generated]), "type PricingMemoryWrapperImpl struct")
+	assert.NoFileExists(t, generated)
+}
+
+func TestWriterSink(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteFiles(NewWriterSink(buf), []File{{Path: "/src/a.sirish.go", Content: []byte("package a\n")}}))
+	assert.Equal(t, "// sirish: /src/a.sirish.go\npackage a\n\n", buf.String())
+}
+
+func TestArchiveSinks(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "src")
+	files := []File{
+		{Path: filepath.Join(root, "b", "b.sirish.go"), Content: []byte("package b\n")},
+		{Path: filepath.Join(root, "a.sirish.go"), Content: []byte("package a\n")},
+	}
+
+	tarball := new(bytes.Buffer)
+	require.NoError(t, WriteFiles(NewTarSink(tarball, root), files))
+	tr := tar.NewReader(tarball)
+	var tarNames []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		tarNames = append(tarNames, header.Name)
+	}
+	assert.Equal(t, []string{"a.sirish.go", "b/b.sirish.go"}, tarNames)
+
+	archive := new(bytes.Buffer)
+	require.NoError(t, WriteFiles(NewZipSink(archive, root), files))
+	zr, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	assert.Equal(t, "a.sirish.go", zr.File[0].Name)
+	entry, err := zr.File[1].Open()
+	require.NoError(t, err)
+	content, err := io.ReadAll(entry)
+	require.NoError(t, err)
+	assert.Equal(t, "package b\n", string(content))
+
+	err = WriteFiles(NewTarSink(io.Discard, filepath.Join(root, "b")), files)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is outside of the archive root")
+}
+
+func TestDryRunSink(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "same.sirish.go"), []byte("package same\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.sirish.go"), []byte("package old\n"), 0o644))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteFiles(NewDryRunSink(buf, dir), []File{
+		{Path: filepath.Join(dir, "new.sirish.go"), Content: []byte("package new\n")},
+		{Path: filepath.Join(dir, "same.sirish.go"), Content: []byte("package same\n")},
+		{Path: filepath.Join(dir, "old.sirish.go"), Content: []byte("package old // changed\n")},
+	}))
+	assert.Equal(t, "create    new.sirish.go\nunchanged same.sirish.go\nmodify    old.sirish.go\n", buf.String())
+	assert.NoFileExists(t, filepath.Join(dir, "new.sirish.go"))
+}
+
+func TestDiskSink(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "out", "a.sirish.go")
+	require.NoError(t, WriteFiles(NewDiskSink(), []File{{Path: p, Content: []byte("package a\n")}}))
+	content, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "package a\n", string(content))
+	info, err := os.Stat(p)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	// the same content keeps the file as it is
+	old := time.Unix(1000, 0)
+	require.NoError(t, os.Chtimes(p, old, old))
+	require.NoError(t, WriteFiles(NewDiskSink(), []File{{Path: p, Content: []byte("package a\n")}}))
+	info, err = os.Stat(p)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(old))
+
+	require.NoError(t, WriteFiles(NewDiskSink(), []File{{Path: p, Content: []byte("package a // changed\n")}}))
+	content, err = os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, "package a // changed\n", string(content))
+
+	// no temporary file is left next to the output
+	entries, err := os.ReadDir(filepath.Dir(p))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "a.sirish.go", entries[0].Name())
+}
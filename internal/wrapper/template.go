@@ -0,0 +1,102 @@
+package wrapper
+
+import (
+	"fmt"
+	"github.com/pm1381/sirish/internal/dto"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"text/template"
+)
+
+// TemplateDataVersion is the version of TemplateData. it is bumped whenever a field is removed or changes
+// meaning, new fields keep it. a template needing a newer version says so with {{/* sirish:data-version N */}}
+const TemplateDataVersion = 1
+
+// EntryTemplate is the template executed when a directory of templates is given
+const EntryTemplate = "wrapper.gotmpl"
+
+// TemplateData is what wrapper templates are executed with, once for every wrapped interface
+type TemplateData struct {
+	DataVersion  int                       // TemplateDataVersion of the running sirish
+	Version      string                    // sirish version written in the header
+	Interface    dto.InterfaceInfo         // the interface to wrap, with its methods and where the wrapper goes
+	Imports      dto.PkgImports            // import path to name of every package the wrapper needs
+	Suffix       string                    // suffix of the generated file names, e.g. sirish
+	TypeName     string                    // interface name with TypeSuffix, e.g. ProfileStoreSirish
+	TypeSuffix   string                    // suffix as it appears in type names, e.g. Sirish
+	HelperPrefix string                    // prefix of unexported helpers, e.g. profileStoreSirish
+	CreateTx     bool                      // methods without a context start a transaction
+	Streams      bool                      // spans of streaming results stay open until the stream is consumed
+	Closers      bool                      // spans of closable results stay open until they are closed
+	Callbacks    bool                      // function params receiving a context are traced as child spans
+	CloserKinds  map[dto.CloserKind]string // io closers returned by traced methods and their printable types
+	SpanType     string                    // span type of wrappers constructed with an empty one, empty keeps it empty
+	Labels       map[string]string         // labels set on every span next to the wrapper name
+}
+
+var dataVersionDirective = regexp.MustCompile(`sirish:data-version\s+(\d+)`)
+
+// ParseTemplate parses the files of f matching patterns. the first file is executed for every interface,
+// the others are partials it can use through template and define
+func ParseTemplate(f fs.FS, patterns ...string) (*template.Template, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(f, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("template pattern %q matches no files", pattern)
+		}
+		files = append(files, matches...)
+	}
+
+	root := template.New(path.Base(files[0])).Funcs(FuncMap())
+	parsed := make(map[string]bool, len(files))
+	for _, file := range files {
+		if parsed[file] {
+			continue
+		}
+		parsed[file] = true
+		src, err := fs.ReadFile(f, file)
+		if err != nil {
+			return nil, err
+		}
+		if match := dataVersionDirective.FindSubmatch(src); match != nil {
+			if version, _ := strconv.Atoi(string(match[1])); version > TemplateDataVersion {
+				return nil, fmt.Errorf("template %s needs data version %d, this sirish provides %d", file, version, TemplateDataVersion)
+			}
+		}
+		tmpl := root
+		if name := path.Base(file); name != root.Name() {
+			tmpl = root.New(name)
+		}
+		if _, err = tmpl.Parse(string(src)); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// LoadTemplate parses a template file from disk. for a directory, its wrapper.gotmpl is executed and
+// its other .gotmpl files are the partials
+func LoadTemplate(p string) (*template.Template, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	var tmpl *template.Template
+	if info.IsDir() {
+		tmpl, err = ParseTemplate(os.DirFS(p), EntryTemplate, "*.gotmpl")
+	} else {
+		tmpl, err = ParseTemplate(os.DirFS(filepath.Dir(p)), filepath.Base(p))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading template %s: %w", p, err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,131 @@
+package wrapper
+
+import (
+	"github.com/pm1381/sirish/internal"
+	"github.com/pm1381/sirish/internal/dto"
+	"github.com/pm1381/sirish/internal/visitors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTemplates writes files into a temporary directory and returns it
+func writeTemplates(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+// generateWith generates the wrapper of NoParams with the template at p into a temporary directory
+func generateWith(t *testing.T, p string) (string, error) {
+	tmpl, err := LoadTemplate(p)
+	if err != nil {
+		return "", err
+	}
+	outDir := t.TempDir()
+	typeVisitor := visitors.NewTypeVisitor(internal.GetTestPathHelper("interface_samples.go", ""), dto.Types{"NoParams"})
+	typeVisitor.SetOutput(outDir, "custom")
+	require.NoError(t, typeVisitor.Traverse())
+
+	err = NewApmWrapperWithTemplate("sirish", tmpl, typeVisitor.GetWrappedInterfaces()).Generate(APMTypeWrapperOptions{
+		GeneralOptions: GeneralOptions{Version: "0.0.1"},
+	})
+	if err != nil {
+		return "", err
+	}
+	generated, err := os.ReadFile(filepath.Join(outDir, "interface_samples.sirish.go"))
+	require.NoError(t, err)
+	return string(generated), nil
+}
+
+func TestLoadTemplateWorks(t *testing.T) {
+	type scenario struct {
+		files map[string]string
+		path  string
+		want  string
+	}
+	scenarios := map[string]scenario{
+		"File": {
+			files: map[string]string{"custom.gotmpl": "package {{ .Interface.OutPackage }}\n\n// {{ .TypeName }} v{{ .DataVersion }}\n"},
+			path:  "custom.gotmpl",
+			want:  "package custom\n\n// NoParamsSirish v1\n",
+		},
+		"DirectoryWithPartials": {
+			files: map[string]string{
+				"wrapper.gotmpl": "{{ template \"header\" . }}\npackage {{ .Interface.OutPackage }}\n",
+				"header.gotmpl":  "{{ define \"header\" }}// {{ .Interface.QualifiedName }}{{ end }}",
+			},
+			path: ".",
+			want: "// test_samples.NoParams\npackage custom\n",
+		},
+		"SupportedDataVersion": {
+			files: map[string]string{"custom.gotmpl": "{{/* sirish:data-version 1 */}}package {{ .Interface.OutPackage }}\n"},
+			path:  "custom.gotmpl",
+			want:  "package custom\n",
+		},
+	}
+	for name, s := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			dir := writeTemplates(t, s.files)
+			generated, err := generateWith(t, filepath.Join(dir, s.path))
+			require.NoError(t, err)
+			assert.Equal(t, s.want, generated)
+		})
+	}
+}
+
+func TestLoadTemplateFails(t *testing.T) {
+	type scenario struct {
+		files map[string]string
+		path  string
+		want  []string
+	}
+	scenarios := map[string]scenario{
+		"ExecutionNamesLine": {
+			files: map[string]string{"custom.gotmpl": "package {{ .Interface.OutPackage }}\n\n// {{ .Nope }}\n"},
+			path:  "custom.gotmpl",
+			want:  []string{"generating wrapper of NoParams", "custom.gotmpl:3:", "can't evaluate field Nope"},
+		},
+		"ParseNamesLine": {
+			files: map[string]string{"custom.gotmpl": "package x\n{{ if }}\n"},
+			path:  "custom.gotmpl",
+			want:  []string{"loading template", "custom.gotmpl:2:"},
+		},
+		"PartialNamesLine": {
+			files: map[string]string{
+				"wrapper.gotmpl": "{{ template \"header\" . }}\n",
+				"header.gotmpl":  "{{ define \"header\" }}\n{{ .Interface.Missing }}{{ end }}",
+			},
+			path: ".",
+			want: []string{"header.gotmpl:2:", "can't evaluate field Missing"},
+		},
+		"NewerDataVersion": {
+			files: map[string]string{"custom.gotmpl": "{{/* sirish:data-version 99 */}}\n"},
+			path:  "custom.gotmpl",
+			want:  []string{"needs data version 99, this sirish provides 1"},
+		},
+		"DirectoryWithoutEntry": {
+			files: map[string]string{"header.gotmpl": "{{ define \"header\" }}{{ end }}"},
+			path:  ".",
+			want:  []string{"\"wrapper.gotmpl\" matches no files"},
+		},
+		"Missing": {
+			path: "missing.gotmpl",
+			want: []string{"missing.gotmpl"},
+		},
+	}
+	for name, s := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			dir := writeTemplates(t, s.files)
+			_, err := generateWith(t, filepath.Join(dir, s.path))
+			require.Error(t, err)
+			for _, want := range s.want {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
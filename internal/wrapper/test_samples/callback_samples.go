@@ -0,0 +1,9 @@
+package test_samples
+
+import "context"
+
+type Transactional interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error
+	Each(ctx context.Context, keys []string, visit func(context.Context, string) (bool, error)) error
+	Later(notify func(ctx context.Context, values ...int)) error
+}
@@ -0,0 +1,17 @@
+package test_samples
+
+import (
+	"context"
+	"io"
+)
+
+// Archive gets Close and Find only by embedding io.Closer and Finder, its wrapper implements them too
+type Archive interface {
+	io.Closer
+	Finder
+	Store(ctx context.Context, key string, value []byte) error
+}
+
+type Finder interface {
+	Find(ctx context.Context, key string) ([]byte, error)
+}
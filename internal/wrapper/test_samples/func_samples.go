@@ -0,0 +1,14 @@
+package test_samples
+
+import "context"
+
+type Message struct {
+	Topic string
+	Body  []byte
+}
+
+type Handler func(ctx context.Context, msg Message) error
+
+type Retry func(ctx context.Context, attempt func(ctx context.Context) error) error
+
+type Lookup func(key string) (value string, found bool)
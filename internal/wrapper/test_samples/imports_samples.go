@@ -0,0 +1,20 @@
+package test_samples
+
+import (
+	"context"
+	crand "crypto/rand"
+	"io"
+	"math/rand"
+	"net/http"
+)
+
+var _ = http.StatusOK
+
+type UsedImports interface {
+	Read(ctx context.Context, r io.Reader) (<-chan []byte, error)
+	Source(seed int64) rand.Source
+	Crypto() io.ReadCloser
+	Each(fn func(ctx context.Context, src rand.Source) error)
+}
+
+var _ = crand.Reader
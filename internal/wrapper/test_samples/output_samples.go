@@ -0,0 +1,20 @@
+package test_samples
+
+import "context"
+
+type Entry struct {
+	ID     string
+	Amount int64
+}
+
+type Ledger interface {
+	Append(ctx context.Context, entries []Entry) error
+	Find(ctx context.Context, id string) (*Entry, error)
+	Scan(ctx context.Context, from Entry) (<-chan Entry, error)
+	Batch(ctx context.Context) (LedgerBatch, error)
+}
+
+type LedgerBatch interface {
+	Add(ctx context.Context, entry Entry) error
+	Close() error
+}
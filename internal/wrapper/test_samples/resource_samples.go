@@ -0,0 +1,25 @@
+package test_samples
+
+import (
+	"context"
+	"io"
+)
+
+type Storage interface {
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Create(ctx context.Context, key string) (writer io.WriteCloser, err error)
+	Pipe() io.ReadWriteCloser
+	Lock(ctx context.Context) (io.Closer, error)
+	Begin(ctx context.Context) (Tx, error)
+	Session() Session
+	Follow(ctx context.Context, key string) (<-chan string, io.Closer, error)
+}
+
+type Tx interface {
+	Exec(ctx context.Context, query string) error
+	Close() error
+}
+
+type Session interface {
+	Get(ctx context.Context, key string) (string, error)
+}
@@ -0,0 +1,14 @@
+package test_samples
+
+import (
+	"context"
+	"iter"
+	tx "strings"
+
+	"github.com/pm1381/sirish/internal/wrapper/test_samples/sync"
+)
+
+type ShadowedImports interface {
+	Builders() <-chan *tx.Builder
+	Groups(ctx context.Context, group sync.Group) iter.Seq[sync.Group]
+}
@@ -0,0 +1,15 @@
+package test_samples
+
+import (
+	"context"
+	"iter"
+)
+
+type ShadowedParams interface {
+	Receiver(ctx context.Context, w string) (string, error)
+	Transaction(tx int) error
+	Apm(ctx context.Context, apm string) error
+	Context(context context.Context, span string) (int, error)
+	Sync(ctx context.Context, sync int) iter.Seq[int]
+	Locals(streamIn, streamEnd string) (items <-chan string)
+}
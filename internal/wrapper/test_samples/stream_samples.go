@@ -0,0 +1,14 @@
+package test_samples
+
+import (
+	"context"
+	"iter"
+)
+
+type Streams interface {
+	Watch(ctx context.Context, key string) (<-chan string, error)
+	All(ctx context.Context) iter.Seq[int]
+	Pairs() iter.Seq2[string, int]
+	Next(ctx context.Context) (next func() ([]byte, bool), err error)
+	Feed(n int) <-chan int
+}
@@ -0,0 +1,18 @@
+package test_samples
+
+import (
+	"context"
+	"io"
+)
+
+// AuditedBilling gets the methods of *Billing and io.Closer through its embedded fields
+//
+//sirish:struct AuditedBilling
+type AuditedBilling struct {
+	*Billing
+	io.Closer
+}
+
+func (b *AuditedBilling) Audit(ctx context.Context, account string) error {
+	return nil
+}
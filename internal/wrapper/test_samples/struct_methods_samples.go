@@ -0,0 +1,10 @@
+package test_samples
+
+import (
+	"context"
+	stdio "io"
+)
+
+func (b *Billing) Export(ctx context.Context, w stdio.Writer) error {
+	return nil
+}
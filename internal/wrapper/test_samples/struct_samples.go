@@ -0,0 +1,21 @@
+package test_samples
+
+import (
+	"context"
+	"io"
+)
+
+//sirish:struct Billing
+type Billing struct{}
+
+func (b *Billing) Charge(ctx context.Context, account string, cents int64) error {
+	return nil
+}
+
+func (b Billing) Invoice(ctx context.Context, id string) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func (b *Billing) Refunds(ctx context.Context, account string) (<-chan string, error) {
+	return nil, nil
+}
@@ -0,0 +1,4 @@
+package sync
+
+type Group struct {
+}
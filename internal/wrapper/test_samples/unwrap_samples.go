@@ -0,0 +1,8 @@
+package test_samples
+
+import "context"
+
+type Unwrapper interface {
+	Unwrap() error
+	Get(ctx context.Context, key string) (string, error)
+}
@@ -0,0 +1,23 @@
+package test_samples
+
+import (
+	"context"
+	"time"
+)
+
+type Cents int64
+
+type Quote struct {
+	Amount Cents
+}
+
+type Quotes []Quote
+
+type QuoteAlias = Quote
+
+type Pricing interface {
+	Price(ctx context.Context, sku string) (Cents, error)
+	Quote(ctx context.Context, sku string) (Quote, QuoteAlias, *Quote, error)
+	List(ctx context.Context) (Quotes, map[string]Quote, [2]Cents, error)
+	Expires(ctx context.Context) (time.Time, bool, string)
+}
@@ -1,21 +1,49 @@
 package wrapper
 
 type WrapperInterface interface {
-	Generate(opts Options) error
+	Generate(opts Options) error              // writes the wrappers to disk
+	GenerateTo(opts Options, sink Sink) error // writes the wrappers to sink and closes it
+	Render(opts Options) ([]File, error)      // returns the wrappers without writing them
+}
+
+// File is a rendered wrapper
+type File struct {
+	Path      string // where the wrapper is written
+	Content   []byte
+	Interface string // name of the wrapped interface
 }
 
 type Options interface {
 	ValidateOpts() error
 }
 
+// GeneralOptions are sent to plugins too, so their json names are part of the plugin protocol
 type GeneralOptions struct {
-	Version  string
-	Imports  bool
-	CreateTx bool
+	Version   string            `json:"version"`
+	Imports   bool              `json:"imports"`
+	CreateTx  bool              `json:"create_tx"`
+	Streams   bool              `json:"streams"`   // keep spans of streaming results open until the stream is consumed
+	Closers   bool              `json:"closers"`   // keep spans of closable results open until they are closed
+	Callbacks bool              `json:"callbacks"` // trace function params receiving a context as child spans
+	SpanType  string            `json:"span_type"` // span type of wrappers constructed with an empty one
+	Labels    map[string]string `json:"labels"`    // labels set on every span next to the wrapper name
 }
 
 type APMTypeWrapperOptions struct {
 	GeneralOptions
+	Interfaces  map[string]GeneralOptions // options replacing GeneralOptions for the interfaces named by the keys
+	Incremental bool                      // files whose header holds their current source hash are read instead of rendered
+	Jobs        int                       // workers rendering and formatting the wrappers, GOMAXPROCS when not positive
+	Source      string                    // file whose targets are generated, named in the header to find orphans later
+	Directive   string                    // id of the directive of Source the run comes from, also in the header
+}
+
+// forInterface returns the options the wrapper of name is generated with
+func (o APMTypeWrapperOptions) forInterface(name string) GeneralOptions {
+	if opts, ok := o.Interfaces[name]; ok {
+		return opts
+	}
+	return o.GeneralOptions
 }
 
 func (o APMTypeWrapperOptions) ValidateOpts() error {